@@ -2,8 +2,7 @@ package deployfile
 
 import (
 	"bytes"
-	"crypto/md5"
-	"crypto/sha1"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -11,149 +10,352 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/jcmturner/gomvn/metadata"
 	"github.com/jcmturner/gomvn/pom"
 	"github.com/jcmturner/gomvn/repo"
+	"github.com/jcmturner/gomvn/version"
 )
 
-func Upload(repoURL, groupID, artifactID, packaging, version, file, username, password string, cl *http.Client) ([]*url.URL, error) {
-	var uploaded []*url.URL
-	if cl == nil {
-		cl = http.DefaultClient
+// Upload deploys the file as the artifact along with a POM, updating the maven-metadata.xml of the artifact.
+// A -SNAPSHOT version is deployed with files named with the next timestamped version and the version level
+// maven-metadata.xml is published so that maven can resolve the snapshot.
+// Every request is made with basic authentication if a username or password is given, otherwise any authentication is
+// left to the client.
+func Upload(repoURL, groupID, artifactID, packaging, ver, file, username, password string, cl *http.Client) ([]*url.URL, error) {
+	return UploadContext(context.Background(), repoURL, groupID, artifactID, packaging, ver, file, username, password, cl)
+}
+
+// UploadContext deploys the file as the artifact as Upload does, with the context.
+// If the context is cancelled the deployment stops and the URLs already uploaded are returned with the error.
+func UploadContext(ctx context.Context, repoURL, groupID, artifactID, packaging, ver, file, username, password string, cl *http.Client) ([]*url.URL, error) {
+	c := repo.Coordinates{
+		GroupID:    groupID,
+		ArtifactID: artifactID,
+		Extension:  packaging,
+		Version:    ver,
 	}
-	groupURL, versionURL, fileName := repo.ParseCoordinates(repoURL, groupID, artifactID, packaging, version)
+	return UploadAllContext(ctx, repoURL, c, file, nil, nil, username, password, cl)
+}
 
-	// open readers of the artifact
-	f, err := os.Open(file)
+// Attachment is an additional file, such as sources or javadoc, deployed under the same coordinates as the main
+// artifact and distinguished by its classifier and extension.
+type Attachment struct {
+	Classifier string
+	Extension  string
+	File       string
+}
+
+// deployFile is a file to upload and the coordinates it is uploaded as
+type deployFile struct {
+	coords repo.Coordinates
+	path   string
+}
+
+// UploadCoordinates deploys the file as the artifact identified by the coordinates, as Upload does.
+func UploadCoordinates(repoURL string, c repo.Coordinates, file, username, password string, cl *http.Client) ([]*url.URL, error) {
+	return UploadAll(repoURL, c, file, nil, nil, username, password, cl)
+}
+
+// UploadAll deploys the file as the artifact identified by the coordinates along with the attachments.
+// All the files and their checksums are uploaded before the POM and maven-metadata.xml, which are written once.
+// A checksum file is published alongside each file for each of the algorithms, repo.DefaultChecksums if none are given.
+func UploadAll(repoURL string, c repo.Coordinates, file string, attachments []Attachment, algorithms []repo.ChecksumAlgorithm, username, password string, cl *http.Client) ([]*url.URL, error) {
+	return UploadAllContext(context.Background(), repoURL, c, file, attachments, algorithms, username, password, cl)
+}
+
+// UploadAllContext deploys the file and attachments as UploadAll does, with the context.
+// If the context is cancelled the deployment stops and the URLs already uploaded are returned with the error.
+func UploadAllContext(ctx context.Context, repoURL string, c repo.Coordinates, file string, attachments []Attachment, algorithms []repo.ChecksumAlgorithm, username, password string, cl *http.Client) ([]*url.URL, error) {
+	return UploadAllWithOptions(ctx, repoURL, c, file, attachments, algorithms, username, password, repo.FetchOptions{}, cl)
+}
+
+// UploadAllWithOptions deploys the file and attachments as UploadAllContext does, verifying the maven-metadata.xml
+// files read from the repository according to the options. Use repo.ChecksumPolicyWarn or repo.ChecksumPolicyIgnore
+// for a repository whose metadata was published without checksums.
+func UploadAllWithOptions(ctx context.Context, repoURL string, c repo.Coordinates, file string, attachments []Attachment, algorithms []repo.ChecksumAlgorithm, username, password string, opts repo.FetchOptions, cl *http.Client) ([]*url.URL, error) {
+	cl, err := authClient(repoURL, username, password, cl)
 	if err != nil {
-		return uploaded, fmt.Errorf("could not open artifact file: %v", err)
+		return nil, err
 	}
-	defer f.Close()
-	rw := new(bytes.Buffer)
-	t := io.TeeReader(f, rw)
+	return uploadAll(ctx, repoURL, c, file, attachments, algorithms, opts, cl, nil)
+}
 
-	// PUT the artifact
-	u, err := url.Parse(versionURL + fileName)
-	if err != nil {
-		return uploaded, fmt.Errorf("target URL for artifact not avlid: %v", err)
+// uploadAll deploys the file and attachments, verifying the metadata read according to the options. If the transaction is not nil the version level maven-metadata.xml is
+// saved to it before it is overwritten, and the version being listed in the artifact level maven-metadata.xml is
+// recorded.
+func uploadAll(ctx context.Context, repoURL string, c repo.Coordinates, file string, attachments []Attachment, algorithms []repo.ChecksumAlgorithm, opts repo.FetchOptions, cl *http.Client, tx *transaction) ([]*url.URL, error) {
+	var uploaded []*url.URL
+	if len(algorithms) == 0 {
+		algorithms = repo.DefaultChecksums
 	}
-	req, err := http.NewRequest("PUT", u.String(), t)
-	if err != nil {
-		return uploaded, fmt.Errorf("could not create upload request for the artifact")
+	for _, alg := range algorithms {
+		if alg.Hash() == nil {
+			return uploaded, fmt.Errorf("unsupported checksum algorithm %q", alg)
+		}
 	}
-	req.SetBasicAuth(username, password)
-	resp, err := cl.Do(req)
-	if err != nil {
-		return uploaded, fmt.Errorf("error uploading the artifact: %v", err)
+	if err := c.Validate(); err != nil {
+		return uploaded, fmt.Errorf("invalid coordinates: %v", err)
 	}
-	if resp.StatusCode != http.StatusCreated {
-		return uploaded, fmt.Errorf("return code when uploading the artifact %d", resp.StatusCode)
+	if c.Extension == "" {
+		c.Extension = repo.DefaultExtension
 	}
-	uploaded = append(uploaded, u)
-	// PUT artifact hash files
-	us, err := uploadHashFiles(rw, versionURL, fileName, username, password, cl)
+	groupID, artifactID, ver := c.GroupID, c.ArtifactID, c.Version
+	pc := c.POM()
+	versionURL := c.VersionURL(repoURL)
+
+	// the coordinates of each file to upload, the main artifact first
+	files := []deployFile{{c, file}}
+	seen := map[string]bool{c.String(): true, pc.String(): true}
+	for _, a := range attachments {
+		ac := repo.Coordinates{
+			GroupID:    groupID,
+			ArtifactID: artifactID,
+			Extension:  a.Extension,
+			Classifier: a.Classifier,
+			Version:    ver,
+		}
+		if ac.Extension == "" {
+			ac.Extension = repo.DefaultExtension
+		}
+		if err := ac.Validate(); err != nil {
+			return uploaded, fmt.Errorf("invalid attachment: %v", err)
+		}
+		if seen[ac.String()] {
+			return uploaded, fmt.Errorf("attachment %s duplicates another file of the deployment", ac.String())
+		}
+		seen[ac.String()] = true
+		files = append(files, deployFile{ac, a.File})
+	}
+	pomName := pc.FileName()
+
+	// SNAPSHOT files are named with a timestamped version recorded in the version level metadata
+	var vmd metadata.MetaData
+	var ts version.Timestamped
+	v, err := version.New(ver)
 	if err != nil {
-		return uploaded, err
+		return uploaded, fmt.Errorf("invalid version: %v", err)
+	}
+	snapshot := v.IsSnapshot()
+	if snapshot {
+		var err error
+		vmd, ts, err = metadata.GenerateSnapshotContext(ctx, repoURL, groupID, artifactID, ver, time.Now(), opts, cl)
+		if err != nil {
+			return uploaded, fmt.Errorf("error generating snapshot version metadata: %v", err)
+		}
+		for _, f := range files {
+			vmd.AddSnapshotVersion(f.coords.Classifier, f.coords.Extension, ts)
+		}
+		vmd.AddSnapshotVersion("", pc.Extension, ts)
+		pomName = pc.FileNameForVersion(ts.String())
+	}
+
+	// PUT the artifact and attachments
+	for _, f := range files {
+		fileName := f.coords.FileName()
+		if snapshot {
+			fileName = f.coords.FileNameForVersion(ts.String())
+		}
+		us, err := uploadFile(ctx, f.path, versionURL, fileName, algorithms, cl)
+		uploaded = append(uploaded, us...)
+		if err != nil {
+			return uploaded, err
+		}
 	}
-	uploaded = append(uploaded, us...)
 
 	// PUT POM
-	p := pom.New(groupID, artifactID, version, packaging)
+	p := pom.New(groupID, artifactID, ver, c.Extension)
 	pb, err := p.Marshal()
 	if err != nil {
 		return uploaded, fmt.Errorf("error marshaling pom: %v", err)
 	}
-	purl, err := pom.URL(repoURL, groupID, artifactID, version)
+	us, err := upload(ctx, bytes.NewReader(pb), int64(len(pb)), versionURL, pomName, nil, algorithms, cl)
+	uploaded = append(uploaded, us...)
 	if err != nil {
-		return uploaded, fmt.Errorf("URL for POM not valid: %v", err)
+		return uploaded, fmt.Errorf("error uploading pom: %v", err)
 	}
-	prw := new(bytes.Buffer)
-	pt := io.TeeReader(bytes.NewReader(pb), prw)
-	req, err = http.NewRequest("PUT", purl.String(), pt)
-	if err != nil {
-		return uploaded, fmt.Errorf("could not create upload request for %s : %v", purl.String(), err)
+
+	// PUT the version level metadata of a snapshot
+	if snapshot {
+		if err := tx.save(ctx, versionURL+metadata.MavenMetadataFile, algorithms, cl); err != nil {
+			return uploaded, err
+		}
+		us, err = uploadMetadata(ctx, vmd, versionURL, nil, algorithms, cl)
+		uploaded = append(uploaded, us...)
+		if err != nil {
+			return uploaded, fmt.Errorf("error uploading snapshot version metadata: %v", err)
+		}
+	}
+
+	// Generate and PUT metadata, merging with any concurrent deployment of another version
+	artifactURL := fmt.Sprintf("%s/%s/", strings.TrimRight(repoURL, "/"), c.ArtifactPath())
+	if err := tx.list(ctx, repoURL, c, artifactURL, algorithms, cl); err != nil {
+		return uploaded, err
 	}
-	req.SetBasicAuth(username, password)
-	resp, err = cl.Do(req)
+	written := make(map[string]bool)
+	_, err = metadata.UpdateContext(ctx, repoURL, groupID, artifactID, ver, metadata.DefaultUpdateAttempts, opts,
+		func(ctx context.Context, md metadata.MetaData, pc metadata.Precondition) error {
+			us, err := uploadMetadata(ctx, md, artifactURL, pc.Header(), algorithms, cl)
+			// each attempt writes the same files so they are only recorded once
+			for _, u := range us {
+				if !written[u.String()] {
+					written[u.String()] = true
+					uploaded = append(uploaded, u)
+				}
+			}
+			return err
+		}, cl)
 	if err != nil {
-		return uploaded, fmt.Errorf("error uploading %s : %v", purl.String(), err)
+		return uploaded, fmt.Errorf("error updating metadata: %v", err)
 	}
-	if resp.StatusCode != http.StatusCreated {
-		return uploaded, fmt.Errorf("uploading %s: return code %d", purl.String(), resp.StatusCode)
+	return uploaded, nil
+}
+
+// authClient returns the client a deployment is made with, which authenticates every request to the repository, the
+// GETs of the metadata as well as the PUTs, with basic authentication if a username or password is given. If neither
+// are given authentication is left to the client, for example one from repo.NewAuthClient.
+// http.DefaultClient is used if cl is nil.
+func authClient(repoURL, username, password string, cl *http.Client) (*http.Client, error) {
+	if cl == nil {
+		cl = http.DefaultClient
 	}
-	uploaded = append(uploaded, purl)
-	// PUT POM hash files
-	pomName := fmt.Sprintf("%s-%s.pom", artifactID, version)
-	us, err = uploadHashFiles(prw, versionURL, pomName, username, password, cl)
-	if err != nil {
-		return uploaded, fmt.Errorf("error uploading pom hash files: %v", err)
+	if username == "" && password == "" {
+		return cl, nil
 	}
-	uploaded = append(uploaded, us...)
+	return repo.NewAuthClient(repoURL, repo.BasicAuth{Username: username, Password: password}, cl)
+}
 
-	// Generate and PUT metadata
-	md, err := metadata.Generate(repoURL, groupID, artifactID, version, cl)
+// uploadFile streams the file at path and its hash files to the location
+func uploadFile(ctx context.Context, path, locationURL, fileName string, algorithms []repo.ChecksumAlgorithm, cl *http.Client) ([]*url.URL, error) {
+	// open readers of the artifact
+	f, err := os.Open(path)
 	if err != nil {
-		return uploaded, fmt.Errorf("error updating metadata: %v", err)
+		return nil, fmt.Errorf("could not open artifact file: %v", err)
 	}
-	mdb, err := md.Marshal()
+	defer f.Close()
+	fi, err := f.Stat()
 	if err != nil {
-		return uploaded, fmt.Errorf("error marshaling metadata: %v", err)
+		return nil, fmt.Errorf("could not stat artifact file: %v", err)
 	}
-	mdPath := fmt.Sprintf("%s%s/%s", groupURL, artifactID, metadata.MavenMetadataFile)
-	murl, err := url.Parse(mdPath)
+	return upload(ctx, f, fi.Size(), locationURL, fileName, nil, algorithms, cl)
+}
+
+// uploadMetadata PUTs the metadata and its hash files to the location, the metadata with the header
+func uploadMetadata(ctx context.Context, md metadata.MetaData, locationURL string, h http.Header, algorithms []repo.ChecksumAlgorithm, cl *http.Client) ([]*url.URL, error) {
+	mdb, err := md.Marshal()
 	if err != nil {
-		return uploaded, fmt.Errorf("URL for metadata not valid: %v", err)
+		return nil, fmt.Errorf("error marshaling metadata: %v", err)
 	}
-	mdrw := new(bytes.Buffer)
-	mdt := io.TeeReader(bytes.NewReader(mdb), mdrw)
-	req, err = http.NewRequest("PUT", murl.String(), mdt)
+	return upload(ctx, bytes.NewReader(mdb), int64(len(mdb)), locationURL, metadata.MavenMetadataFile, h, algorithms, cl)
+}
+
+// checksum is a hash uploaded alongside each file with the suffix
+type checksum struct {
+	suffix string
+	hash   hash.Hash
+}
+
+func checksums(algorithms []repo.ChecksumAlgorithm) []checksum {
+	cs := make([]checksum, len(algorithms))
+	for i, alg := range algorithms {
+		cs[i] = checksum{string(alg), alg.Hash()}
+	}
+	return cs
+}
+
+// upload streams the size bytes of the reader to the location, computing the checksums as it is read so that the
+// content is never held in memory. The hash files are then PUT.
+// The header is added to the request, such as to make it conditional. A metadata.Conflict error is returned if the
+// condition fails.
+func upload(ctx context.Context, r io.Reader, size int64, locationURL, fileName string, h http.Header, algorithms []repo.ChecksumAlgorithm, cl *http.Client) ([]*url.URL, error) {
+	var uploaded []*url.URL
+	cs := checksums(algorithms)
+	hws := make([]io.Writer, len(cs))
+	for i := range cs {
+		hws[i] = cs[i].hash
+	}
+	body := newCountingReader(io.TeeReader(r, io.MultiWriter(hws...)))
+
+	u, err := url.Parse(locationURL + fileName)
 	if err != nil {
-		return uploaded, fmt.Errorf("could not create upload request for %s : %v", mdPath, err)
+		return uploaded, fmt.Errorf("target URL for %s not valid: %v", fileName, err)
 	}
-	req.SetBasicAuth(username, password)
-	resp, err = cl.Do(req)
+	req, err := http.NewRequestWithContext(ctx, "PUT", u.String(), body)
 	if err != nil {
-		return uploaded, fmt.Errorf("error uploading %s : %v", mdPath, err)
+		return uploaded, fmt.Errorf("could not create upload request for %s : %v", u.String(), err)
 	}
-	if resp.StatusCode != http.StatusCreated {
-		return uploaded, fmt.Errorf("uploading %s: return code %d", mdPath, resp.StatusCode)
+	req.ContentLength = size
+	for k, vs := range h {
+		req.Header[k] = vs
 	}
-	uploaded = append(uploaded, murl)
-	// PUT metadata hash files
-	mdPath = fmt.Sprintf("%s%s/", groupURL, artifactID)
-	us, err = uploadHashFiles(mdrw, mdPath, metadata.MavenMetadataFile, username, password, cl)
+	if size == 0 {
+		// a zero length with a body is treated as unknown length so send no body
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		body.Close()
+	} else if rs, ok := r.(io.Seeker); ok {
+		// the body can be sent again, such as when the request is retried, by reading from the start again
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err == nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				// wait for the transport to finish with the previous body
+				<-body.closed
+				if _, err := rs.Seek(start, io.SeekStart); err != nil {
+					return nil, err
+				}
+				for _, c := range cs {
+					c.hash.Reset()
+				}
+				body = newCountingReader(io.TeeReader(r, io.MultiWriter(hws...)))
+				return body, nil
+			}
+		}
+	}
+	resp, err := cl.Do(req)
 	if err != nil {
-		return uploaded, fmt.Errorf("error uploading metadata hash files: %v", err)
+		return uploaded, fmt.Errorf("error uploading %s : %v", u.String(), err)
+	}
+	resp.Body.Close()
+	// the transport may still be reading the body after the response, it is done once it closes the body
+	<-body.closed
+	if resp.StatusCode == http.StatusPreconditionFailed && len(h) > 0 {
+		return uploaded, metadata.Conflict{
+			ErrorString: fmt.Sprintf("uploading %s: changed since it was read", u.String()),
+		}
+	}
+	if !stored(resp.StatusCode) {
+		return uploaded, fmt.Errorf("uploading %s: return code %d", u.String(), resp.StatusCode)
 	}
+	if body.n != size {
+		return uploaded, fmt.Errorf("uploading %s: %d bytes sent, expected %d", u.String(), body.n, size)
+	}
+	uploaded = append(uploaded, u)
+	// PUT hash files
+	us, err := uploadHashFiles(ctx, cs, locationURL, fileName, cl)
 	uploaded = append(uploaded, us...)
+	if err != nil {
+		return uploaded, fmt.Errorf("error uploading %s hash files: %v", fileName, err)
+	}
 	return uploaded, nil
 }
 
-func uploadHashFiles(r io.Reader, locationURL, filename, username, password string, cl *http.Client) ([]*url.URL, error) {
+// stored reports if the status code of a PUT is one a repository returns for a file stored: 201 Created for a new
+// file, or 200 OK or 204 No Content for one replaced, as a conditional write of metadata always does
+func stored(code int) bool {
+	return code == http.StatusCreated || code == http.StatusOK || code == http.StatusNoContent
+}
+
+func uploadHashFiles(ctx context.Context, cs []checksum, locationURL, filename string, cl *http.Client) ([]*url.URL, error) {
 	var uploaded []*url.URL
 	if cl == nil {
 		cl = http.DefaultClient
 	}
-	hs := []struct {
-		suffix string
-		hash   hash.Hash
-	}{
-		{"sha1", sha1.New()},
-		{"md5", md5.New()},
-		//{"sha256", sha256.New()},
-	}
-	// Create a chain of readers to use as each is read to upload
-	readers := make([]*bytes.Buffer, len(hs)-1)
-	t := r
-	for i := 1; i < len(hs); i++ {
-		rw := new(bytes.Buffer)
-		t = io.TeeReader(t, rw)
-		readers[len(readers)-i] = rw
-	}
-	for i, h := range hs {
-		turl := locationURL + filename + "." + h.suffix
-		req, err := hashPutRequest(t, h.hash, turl, username, password)
+	for _, c := range cs {
+		turl := locationURL + filename + "." + c.suffix
+		req, err := hashPutRequest(ctx, c.hash, turl)
 		if err != nil {
 			return uploaded, fmt.Errorf("could not generate request to put %s : %v", turl, err)
 		}
@@ -161,32 +363,47 @@ func uploadHashFiles(r io.Reader, locationURL, filename, username, password stri
 		if err != nil {
 			return uploaded, fmt.Errorf("error uploading %s : %v", turl, err)
 		}
-		if resp.StatusCode != http.StatusCreated {
+		resp.Body.Close()
+		if !stored(resp.StatusCode) {
 			return uploaded, fmt.Errorf("uploading %s: return code %d", turl, resp.StatusCode)
 		}
 		u, err := url.Parse(turl)
 		if err == nil {
 			uploaded = append(uploaded, u)
 		}
-		if i < len(readers) {
-			t = readers[i]
-		}
 	}
 	return uploaded, nil
 }
 
-func hashPutRequest(f io.Reader, h hash.Hash, targetURL, username, password string) (*http.Request, error) {
-	_, err := io.Copy(h, f)
-	if err != nil {
-		return nil, err
-	}
+func hashPutRequest(ctx context.Context, h hash.Hash, targetURL string) (*http.Request, error) {
 	hashb := h.Sum(nil)
 	hexb := make([]byte, hex.EncodedLen(len(hashb)))
 	hex.Encode(hexb, hashb)
-	req, err := http.NewRequest("PUT", targetURL, bytes.NewReader(hexb))
-	if err != nil {
-		return req, err
+	return http.NewRequestWithContext(ctx, "PUT", targetURL, bytes.NewReader(hexb))
+}
+
+// countingReader is a request body that counts the bytes read through it and signals when it is closed
+type countingReader struct {
+	r      io.Reader
+	n      int64
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newCountingReader(r io.Reader) *countingReader {
+	return &countingReader{
+		r:      r,
+		closed: make(chan struct{}),
 	}
-	req.SetBasicAuth(username, password)
-	return req, nil
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
 }
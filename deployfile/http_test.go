@@ -1,11 +1,24 @@
 package deployfile
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/jcmturner/gomvn/metadata"
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/stretchr/testify/assert"
 )
 
 const (
@@ -46,14 +59,25 @@ const (
 )
 
 func testServer(badsha bool) *httptest.Server {
+	// the metadata is read back once updated
+	var mu sync.Mutex
+	md := []byte(mavenMetaData)
+	var updated bool
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
 		switch r.Method {
 		case http.MethodGet:
 			switch r.RequestURI {
 			case mavenMetadataURL:
-				w.Write([]byte(mavenMetaData))
+				w.Write(md)
 				return
 			case mavenMetadataURL + ".sha1":
+				if updated {
+					// the checksum of the updated metadata is not kept
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
 				if badsha {
 					w.Write([]byte("invalid"))
 					return
@@ -61,12 +85,18 @@ func testServer(badsha bool) *httptest.Server {
 				w.Write([]byte(mavenMetaDataSHA1))
 				return
 			}
+			w.WriteHeader(http.StatusNotFound)
+			return
 		case http.MethodPut:
 			u, p, ok := r.BasicAuth()
 			if !ok || u != testUsername || p != testPassword {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
+			if r.RequestURI == mavenMetadataURL {
+				md, _ = ioutil.ReadAll(r.Body)
+				updated = true
+			}
 			w.WriteHeader(http.StatusCreated)
 			return
 		default:
@@ -112,3 +142,688 @@ func TestUploadInvalidMetadata(t *testing.T) {
 		t.Error("upload should have errored for an invalid sha1 of the metadata")
 	}
 }
+
+// repoServer is an in memory repository that serves the files PUT to it, supporting conditional PUTs with ETags
+type repoServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	files map[string][]byte
+	puts  map[string]int
+	// lengths records the Content-Length of each PUT
+	lengths map[string]int64
+	// onPut, if set, is called with the path of each file stored
+	onPut func(path string)
+	// replaced, if set, is the status returned for a PUT that replaces a file, otherwise 201 Created
+	replaced int
+}
+
+func newRepoServer() *repoServer {
+	rs := &repoServer{
+		files:   make(map[string][]byte),
+		puts:    make(map[string]int),
+		lengths: make(map[string]int64),
+	}
+	rs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			b, ok := rs.files[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("ETag", etag(b))
+			w.Write(b)
+		case http.MethodPut:
+			u, p, ok := r.BasicAuth()
+			if !ok || u != testUsername || p != testPassword {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			current, exists := rs.files[r.URL.Path]
+			if m := r.Header.Get("If-Match"); m != "" && (!exists || m != etag(current)) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			if r.Header.Get("If-None-Match") == "*" && exists {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			status := http.StatusCreated
+			if exists && rs.replaced != 0 {
+				status = rs.replaced
+			}
+			rs.files[r.URL.Path] = b
+			rs.puts[r.URL.Path]++
+			rs.lengths[r.URL.Path] = r.ContentLength
+			if rs.onPut != nil {
+				rs.onPut(r.URL.Path)
+			}
+			w.WriteHeader(status)
+		case http.MethodDelete:
+			u, p, ok := r.BasicAuth()
+			if !ok || u != testUsername || p != testPassword {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if _, ok := rs.files[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(rs.files, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	return rs
+}
+
+func etag(b []byte) string {
+	h := sha1.Sum(b)
+	return `"` + hex.EncodeToString(h[:]) + `"`
+}
+
+func (rs *repoServer) file(path string) ([]byte, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	b, ok := rs.files[path]
+	return b, ok
+}
+
+func TestUploadSnapshot(t *testing.T) {
+	s := newRepoServer()
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+
+	for build := 1; build <= 2; build++ {
+		u, err := Upload(s.URL, "com.example", "example", "jar", "1.0-SNAPSHOT", file.Name(), testUsername, testPassword, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(u) != 12 {
+			t.Errorf("expected number of uploaded URLs to be 12 actual: %d", len(u))
+		}
+		vmd, err := metadata.GetVersion(s.URL, "com.example", "example", "1.0-SNAPSHOT", nil)
+		if err != nil {
+			t.Fatalf("error getting version metadata: %v", err)
+		}
+		assert.Equal(t, "1.0-SNAPSHOT", vmd.Version.String())
+		if !assert.NotNil(t, vmd.Versioning.Snapshot) {
+			t.FailNow()
+		}
+		assert.Equal(t, build, vmd.Versioning.Snapshot.BuildNumber)
+		ts, err := vmd.Versioning.Snapshot.Timestamped(*vmd.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.NotNil(t, vmd.Versioning.SnapshotVersions) {
+			t.FailNow()
+		}
+		svs := *vmd.Versioning.SnapshotVersions
+		assert.Equal(t, 2, len(svs), "expected a snapshotVersion for the jar and the pom")
+		for _, sv := range svs {
+			assert.Equal(t, ts.String(), sv.Value)
+			path := fmt.Sprintf("/com/example/example/1.0-SNAPSHOT/example-%s.%s", sv.Value, sv.Extension)
+			if _, ok := s.file(path); !ok {
+				t.Errorf("timestamped file %s not uploaded", path)
+			}
+			if b, _ := s.file(path + ".sha1"); len(b) == 0 {
+				t.Errorf("checksum of %s not uploaded", path)
+			}
+		}
+		if _, ok := s.file("/com/example/example/1.0-SNAPSHOT/example-1.0-SNAPSHOT.jar"); ok {
+			t.Error("non-unique snapshot file should not have been uploaded")
+		}
+	}
+
+	md, err := metadata.Get(s.URL, "com.example", "example", nil)
+	if err != nil {
+		t.Fatalf("error getting metadata: %v", err)
+	}
+	assert.Equal(t, []string{"1.0-SNAPSHOT"}, md.Versioning.Versions.String())
+	assert.Equal(t, "1.0-SNAPSHOT", md.Versioning.Latest.String())
+	assert.Nil(t, md.Versioning.Release, "a snapshot is not a release")
+
+	// the version level metadata checksum is valid
+	b, _ := s.file("/com/example/example/1.0-SNAPSHOT/maven-metadata.xml")
+	h := sha1.Sum(b)
+	sb, _ := s.file("/com/example/example/1.0-SNAPSHOT/maven-metadata.xml.sha1")
+	assert.Equal(t, hex.EncodeToString(h[:]), string(sb))
+}
+
+func TestUploadCoordinates(t *testing.T) {
+	s := newRepoServer()
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+
+	c, err := repo.NewCoordinates("com.example:example:tar.gz:linux-amd64:2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = UploadCoordinates(s.URL, c, file.Name(), testUsername, testPassword, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		"/com/example/example/2.0/example-2.0-linux-amd64.tar.gz",
+		"/com/example/example/2.0/example-2.0-linux-amd64.tar.gz.sha1",
+		"/com/example/example/2.0/example-2.0.pom",
+		"/com/example/example/maven-metadata.xml",
+	} {
+		if _, ok := s.file(path); !ok {
+			t.Errorf("%s not uploaded", path)
+		}
+	}
+
+	_, err = UploadCoordinates(s.URL, repo.Coordinates{GroupID: "com.example", ArtifactID: "example"}, file.Name(), testUsername, testPassword, nil)
+	assert.NotNil(t, err, "upload with invalid coordinates should error")
+}
+
+func TestUploadAll(t *testing.T) {
+	s := newRepoServer()
+	defer s.Close()
+
+	var attachments []Attachment
+	for _, a := range []struct {
+		classifier string
+		extension  string
+	}{
+		{"", "jar"},
+		{"sources", "jar"},
+		{"javadoc", "jar"},
+		{"linux-amd64", ""},
+	} {
+		file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(file.Name())
+		file.WriteString("mock" + a.classifier)
+		attachments = append(attachments, Attachment{Classifier: a.classifier, Extension: a.extension, File: file.Name()})
+	}
+
+	for _, ver := range []string{"1.0", "1.1-SNAPSHOT"} {
+		c := repo.Coordinates{GroupID: "com.example", ArtifactID: "foo", Extension: "jar", Version: ver}
+		u, err := UploadAll(s.URL, c, attachments[0].File, attachments[1:], nil, testUsername, testPassword, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 4 files and a POM, each with 2 hash files, plus the metadata
+		expected := 18
+		if ver == "1.1-SNAPSHOT" {
+			// plus the version level metadata
+			expected = 21
+		}
+		assert.Equal(t, expected, len(u), "number of URLs uploaded for %s", ver)
+	}
+	for _, path := range []string{
+		"/com/example/foo/1.0/foo-1.0.jar",
+		"/com/example/foo/1.0/foo-1.0-sources.jar",
+		"/com/example/foo/1.0/foo-1.0-javadoc.jar",
+		"/com/example/foo/1.0/foo-1.0-linux-amd64.jar",
+		"/com/example/foo/1.0/foo-1.0-sources.jar.md5",
+	} {
+		if _, ok := s.file(path); !ok {
+			t.Errorf("%s not uploaded", path)
+		}
+	}
+	b, _ := s.file("/com/example/foo/1.0/foo-1.0-sources.jar")
+	assert.Equal(t, "mocksources", string(b))
+	assert.Equal(t, 1, s.puts["/com/example/foo/1.0/foo-1.0.pom"], "POM should be uploaded once")
+	assert.Equal(t, 2, s.puts["/com/example/foo/maven-metadata.xml"], "metadata should be uploaded once per deployment")
+
+	vmd, err := metadata.GetVersion(s.URL, "com.example", "foo", "1.1-SNAPSHOT", nil)
+	if err != nil {
+		t.Fatalf("error getting version metadata: %v", err)
+	}
+	assert.Equal(t, 5, len(*vmd.Versioning.SnapshotVersions))
+	v, ok := vmd.SnapshotValue("javadoc", "jar")
+	assert.True(t, ok)
+	if _, ok := s.file("/com/example/foo/1.1-SNAPSHOT/foo-" + v + "-javadoc.jar"); !ok {
+		t.Error("timestamped javadoc not uploaded")
+	}
+
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "foo", Extension: "jar", Version: "2.0"}
+	_, err = UploadAll(s.URL, c, attachments[0].File, attachments[:2], nil, testUsername, testPassword, nil)
+	assert.NotNil(t, err, "attachment duplicating the main artifact should error")
+}
+
+func TestUploadStreaming(t *testing.T) {
+	s := newRepoServer()
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<18)
+	file.Write(content)
+	empty, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(empty.Name())
+
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "big", Extension: "tar.gz", Version: "1.0"}
+	_, err = UploadAll(s.URL, c, file.Name(), []Attachment{{Classifier: "empty", File: empty.Name()}}, nil, testUsername, testPassword, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/com/example/big/1.0/big-1.0.tar.gz"
+	b, _ := s.file(path)
+	assert.True(t, bytes.Equal(content, b), "uploaded content does not match")
+	assert.Equal(t, int64(len(content)), s.lengths[path], "Content-Length not set from the file size")
+	sha := sha1.Sum(content)
+	b, _ = s.file(path + ".sha1")
+	assert.Equal(t, hex.EncodeToString(sha[:]), string(b))
+	md := md5.Sum(content)
+	b, _ = s.file(path + ".md5")
+	assert.Equal(t, hex.EncodeToString(md[:]), string(b))
+
+	path = "/com/example/big/1.0/big-1.0-empty.jar"
+	b, ok := s.file(path)
+	assert.True(t, ok, "empty file not uploaded")
+	assert.Equal(t, 0, len(b))
+	sha = sha1.Sum(nil)
+	b, _ = s.file(path + ".sha1")
+	assert.Equal(t, hex.EncodeToString(sha[:]), string(b))
+}
+
+func TestUploadChecksums(t *testing.T) {
+	s := newRepoServer()
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.0"}
+	algs := []repo.ChecksumAlgorithm{repo.ChecksumSHA512, repo.ChecksumSHA256}
+	u, err := UploadAll(s.URL, c, file.Name(), nil, algs, testUsername, testPassword, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// jar, pom and metadata with two checksums each
+	assert.Equal(t, 9, len(u), "unexpected number of uploaded URLs")
+	for _, path := range []string{
+		"/com/example/example/1.0/example-1.0.jar",
+		"/com/example/example/1.0/example-1.0.pom",
+		"/com/example/example/maven-metadata.xml",
+	} {
+		b, _ := s.file(path)
+		for _, alg := range algs {
+			h := alg.Hash()
+			h.Write(b)
+			cb, _ := s.file(path + "." + string(alg))
+			assert.Equal(t, hex.EncodeToString(h.Sum(nil)), string(cb), "%s checksum of %s", alg, path)
+		}
+		for _, alg := range repo.DefaultChecksums {
+			if _, ok := s.file(path + "." + string(alg)); ok {
+				t.Errorf("%s checksum of %s uploaded when not configured", alg, path)
+			}
+		}
+	}
+	// the metadata is verified against its sha512 checksum when read for the next deployment
+	c.Version = "1.1"
+	_, err = UploadAll(s.URL, c, file.Name(), nil, algs, testUsername, testPassword, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := metadata.Get(s.URL, "com.example", "example", nil)
+	if err != nil {
+		t.Fatalf("error getting metadata: %v", err)
+	}
+	assert.Equal(t, []string{"1.0", "1.1"}, md.Versioning.Versions.String())
+
+	_, err = UploadAll(s.URL, c, file.Name(), nil, []repo.ChecksumAlgorithm{"crc32"}, testUsername, testPassword, nil)
+	if err == nil {
+		t.Error("upload should have errored for an unsupported checksum algorithm")
+	}
+}
+
+func TestUploadAllContext_Cancel(t *testing.T) {
+	s := newRepoServer()
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+
+	// cancel the deployment once the jar has been stored
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.onPut = func(path string) {
+		if path == "/com/example/example/1.0/example-1.0.jar" {
+			cancel()
+		}
+	}
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.0"}
+	u, err := UploadAllContext(ctx, s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	if err == nil {
+		t.Fatal("upload should have errored when cancelled")
+	}
+	for _, uu := range u {
+		if _, ok := s.file(uu.Path); !ok {
+			t.Errorf("%s reported as uploaded but was not stored", uu)
+		}
+	}
+	if _, ok := s.file("/com/example/example/1.0/example-1.0.pom"); ok {
+		t.Error("pom should not have been uploaded after cancellation")
+	}
+	if _, ok := s.file("/com/example/example/maven-metadata.xml"); ok {
+		t.Error("metadata should not have been uploaded after cancellation")
+	}
+
+	_, err = UploadAllContext(ctx, s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	assert.NotNil(t, err, "upload with a cancelled context should error")
+}
+
+func TestUploadAll_Authenticator(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	// a repository requiring a token for every request, which is then handled as the in memory repository
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-JFrog-Art-Api") != "abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.SetBasicAuth(testUsername, testPassword)
+		rs.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.0"}
+	_, err = UploadAll(s.URL, c, file.Name(), nil, nil, "", "", nil)
+	assert.NotNil(t, err, "upload without the token should fail")
+
+	cl, err := repo.NewAuthClient(s.URL, repo.HeaderToken{Header: "X-JFrog-Art-Api", Token: "abc123"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"1.0", "1.1"} {
+		c.Version = v
+		_, err = UploadAll(s.URL, c, file.Name(), nil, nil, "", "", cl)
+		if err != nil {
+			t.Fatalf("error uploading %s with token: %v", v, err)
+		}
+	}
+	md, err := metadata.Get(s.URL, "com.example", "example", cl)
+	if err != nil {
+		t.Fatalf("error getting metadata with token: %v", err)
+	}
+	assert.Equal(t, []string{"1.0", "1.1"}, md.Versioning.Versions.String())
+}
+
+func TestUploadAll_AuthenticatedReads(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	// a repository requiring the credentials for every request, including the GETs of the metadata
+	var mu sync.Mutex
+	var unauthenticated []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != testUsername || p != testPassword {
+			mu.Lock()
+			unauthenticated = append(unauthenticated, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rs.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example"}
+	for _, v := range []string{"1.0", "1.1", "1.2-SNAPSHOT", "1.2-SNAPSHOT"} {
+		c.Version = v
+		_, err = UploadAll(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+		if err != nil {
+			t.Fatalf("error uploading %s: %v", v, err)
+		}
+	}
+	c.Version = "1.3"
+	_, err = UploadAllTransactional(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	if err != nil {
+		t.Fatalf("error uploading %s transactionally: %v", c.Version, err)
+	}
+	assert.Empty(t, unauthenticated, "requests made without credentials")
+
+	md, err := metadata.Get(rs.URL, "com.example", "example", nil)
+	if err != nil {
+		t.Fatalf("error getting metadata: %v", err)
+	}
+	assert.Equal(t, []string{"1.0", "1.1", "1.2-SNAPSHOT", "1.3"}, md.Versioning.Versions.String())
+	vmd, err := metadata.GetVersion(rs.URL, "com.example", "example", "1.2-SNAPSHOT", nil)
+	if err != nil {
+		t.Fatalf("error getting snapshot metadata: %v", err)
+	}
+	assert.Equal(t, 2, vmd.Versioning.Snapshot.BuildNumber, "build number not incremented from the metadata read")
+}
+
+func TestUploadAll_Replaced(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusNoContent} {
+		// a repository that returns the status rather than 201 Created when a file is replaced, as the metadata and
+		// its checksums are by the conditional PUTs of the second deployment
+		rs := newRepoServer()
+		rs.replaced = status
+		file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString("mockartifact")
+		c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example"}
+		for _, v := range []string{"1.0", "1.1", "1.2-SNAPSHOT", "1.2-SNAPSHOT"} {
+			c.Version = v
+			_, err = UploadAll(rs.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+			if err != nil {
+				t.Errorf("error uploading %s with status %d for a replaced file: %v", v, status, err)
+			}
+		}
+		md, err := metadata.Get(rs.URL, "com.example", "example", nil)
+		if err != nil {
+			t.Errorf("error getting metadata: %v", err)
+		} else {
+			assert.Equal(t, []string{"1.0", "1.1", "1.2-SNAPSHOT"}, md.Versioning.Versions.String())
+		}
+		rs.Close()
+		os.Remove(file.Name())
+	}
+}
+
+func TestUploadAllWithOptions(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	// metadata published without checksums
+	md := metadata.New("com.example", "example")
+	md.AddVersion("1.0")
+	b, err := md.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.files["/com/example/example/maven-metadata.xml"] = b
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.1"}
+	_, err = UploadAll(rs.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	if assert.Error(t, err, "metadata without checksums should fail the default checksum policy") {
+		assert.Contains(t, err.Error(), "checksum")
+	}
+	assert.Equal(t, 0, rs.puts["/com/example/example/maven-metadata.xml"], "metadata without checksums should not be written")
+
+	warn := repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyWarn, Logger: log.New(ioutil.Discard, "", 0)}
+	_, err = UploadAllWithOptions(context.Background(), rs.URL, c, file.Name(), nil, nil, testUsername, testPassword, warn, nil)
+	if err != nil {
+		t.Fatalf("error uploading with the warn checksum policy: %v", err)
+	}
+	c.Version = "1.2"
+	_, err = UploadAllTransactionalWithOptions(context.Background(), rs.URL, c, file.Name(), nil, nil, testUsername, testPassword, warn, nil)
+	if err != nil {
+		t.Fatalf("error uploading transactionally with the warn checksum policy: %v", err)
+	}
+	md, err = metadata.Get(rs.URL, "com.example", "example", nil)
+	if err != nil {
+		t.Fatalf("error getting metadata: %v", err)
+	}
+	assert.Equal(t, []string{"1.0", "1.1", "1.2"}, md.Versioning.Versions.String())
+}
+
+func TestUploadAll_Retry(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	// a repository that is unavailable for the first request of each file, after reading part of any content
+	var mu sync.Mutex
+	failed := make(map[string]bool)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fail := !failed[r.Method+" "+r.URL.Path]
+		failed[r.Method+" "+r.URL.Path] = true
+		mu.Unlock()
+		if fail {
+			r.Body.Read(make([]byte, 4))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rs.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	content := "mockartifact"
+	file.WriteString(content)
+
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.0"}
+	_, err = UploadAll(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	assert.NotNil(t, err, "upload without retries should fail")
+
+	mu.Lock()
+	failed = make(map[string]bool)
+	mu.Unlock()
+	cl := repo.NewRetryClient(repo.RetryPolicy{Attempts: 2, Backoff: time.Millisecond}, nil)
+	_, err = UploadAll(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, cl)
+	if err != nil {
+		t.Fatalf("error uploading with retries: %v", err)
+	}
+	jar := "/com/example/example/1.0/example-1.0.jar"
+	b, _ := rs.file(jar)
+	assert.Equal(t, content, string(b), "content not sent again when retried")
+	sha1sum := sha1.Sum([]byte(content))
+	b, _ = rs.file(jar + ".sha1")
+	assert.Equal(t, hex.EncodeToString(sha1sum[:]), string(b), "checksum should be of the content sent once")
+	md5sum := md5.Sum([]byte(content))
+	b, _ = rs.file(jar + ".md5")
+	assert.Equal(t, hex.EncodeToString(md5sum[:]), string(b), "checksum should be of the content sent once")
+	_, ok := rs.file("/com/example/example/maven-metadata.xml")
+	assert.True(t, ok, "metadata not uploaded")
+}
+
+func TestUploadAll_Concurrent(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	vs := []string{"1.0", "1.1", "1.2", "1.3", "1.4"}
+	// hold the writes of the metadata until every deployment has read it, so that they all update the same metadata
+	const mdPath = "/com/example/example/maven-metadata.xml"
+	var mu sync.Mutex
+	var reads int
+	allRead := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == mdPath {
+			switch r.Method {
+			case http.MethodGet:
+				mu.Lock()
+				reads++
+				if reads == len(vs) {
+					close(allRead)
+				}
+				mu.Unlock()
+			case http.MethodPut:
+				<-allRead
+			}
+		}
+		rs.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+
+	// deploy versions of the same artifact at the same time, once there is metadata with an ETag
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "0.9"}
+	if _, err := UploadAll(rs.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil); err != nil {
+		t.Fatalf("error uploading 0.9: %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(vs))
+	for i, v := range vs {
+		wg.Add(1)
+		go func(i int, v string) {
+			defer wg.Done()
+			c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: v}
+			_, errs[i] = UploadAll(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+		}(i, v)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("error uploading %s: %v", vs[i], err)
+		}
+	}
+	md, err := metadata.Get(s.URL, "com.example", "example", nil)
+	if err != nil {
+		t.Fatalf("error getting metadata: %v", err)
+	}
+	assert.Equal(t, append([]string{"0.9"}, vs...), md.Versioning.Versions.String(), "a concurrently deployed version was lost")
+}
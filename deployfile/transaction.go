@@ -0,0 +1,267 @@
+package deployfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jcmturner/gomvn/metadata"
+	"github.com/jcmturner/gomvn/repo"
+)
+
+// RollbackError is returned when a transactional deployment fails. Err is the error the deployment failed with.
+// The files uploaded before the failure are listed in RolledBack if they were removed, or restored to their previous
+// content, and in NotRolledBack if they could not be.
+type RollbackError struct {
+	ErrorString   string
+	Err           error
+	RolledBack    []*url.URL
+	NotRolledBack []*url.URL
+}
+
+func (e RollbackError) Error() string {
+	return e.ErrorString
+}
+
+// UploadAllTransactional deploys the file and attachments as UploadAll does, removing the files already uploaded if
+// the deployment fails.
+func UploadAllTransactional(repoURL string, c repo.Coordinates, file string, attachments []Attachment, algorithms []repo.ChecksumAlgorithm, username, password string, cl *http.Client) ([]*url.URL, error) {
+	return UploadAllTransactionalContext(context.Background(), repoURL, c, file, attachments, algorithms, username, password, cl)
+}
+
+// UploadAllTransactionalContext deploys the file and attachments as UploadAllContext does, rolling back the deployment
+// if it fails. The maven-metadata.xml files are uploaded last so the new version is only published once all its
+// files are in place.
+// On failure the version is first removed from the artifact level maven-metadata.xml, if it was not listed before,
+// with the same conditional update used to add it so that versions deployed concurrently are kept. The other files
+// uploaded are then DELETEd in reverse order, except the version level maven-metadata.xml of a SNAPSHOT and its
+// checksums which are restored to the content they had before the deployment. Rolling back is best effort and
+// continues even if the context is cancelled. A RollbackError is returned reporting the files rolled back and those
+// that were not, which are also returned as the URLs uploaded.
+func UploadAllTransactionalContext(ctx context.Context, repoURL string, c repo.Coordinates, file string, attachments []Attachment, algorithms []repo.ChecksumAlgorithm, username, password string, cl *http.Client) ([]*url.URL, error) {
+	return UploadAllTransactionalWithOptions(ctx, repoURL, c, file, attachments, algorithms, username, password, repo.FetchOptions{}, cl)
+}
+
+// UploadAllTransactionalWithOptions deploys the file and attachments as UploadAllTransactionalContext does, verifying
+// the maven-metadata.xml files read from the repository according to the options, as UploadAllWithOptions does.
+func UploadAllTransactionalWithOptions(ctx context.Context, repoURL string, c repo.Coordinates, file string, attachments []Attachment, algorithms []repo.ChecksumAlgorithm, username, password string, opts repo.FetchOptions, cl *http.Client) ([]*url.URL, error) {
+	cl, err := authClient(repoURL, username, password, cl)
+	if err != nil {
+		return nil, err
+	}
+	tx := &transaction{previous: make(map[string][]byte)}
+	uploaded, err := uploadAll(ctx, repoURL, c, file, attachments, algorithms, opts, cl, tx)
+	if err == nil {
+		return uploaded, nil
+	}
+	// the deployment may have failed because the context was cancelled, which must not stop the rollback
+	rb := tx.rollback(context.Background(), uploaded, cl)
+	rb.Err = err
+	rb.ErrorString = fmt.Sprintf("deployment failed: %v; %s", err, rb.ErrorString)
+	return rb.NotRolledBack, rb
+}
+
+// transaction records the content of the files a deployment overwrites so that they can be restored, and the
+// version it lists in the artifact level metadata so that it can be removed
+type transaction struct {
+	previous map[string][]byte
+	// listing is the artifact level metadata the version is added to
+	listing *listing
+}
+
+// listing is the version added to the artifact level metadata in the directory at url, with the checksum algorithms
+// it is written with. Listed is true if the version was already listed before the deployment.
+type listing struct {
+	repoURL    string
+	c          repo.Coordinates
+	url        string
+	algorithms []repo.ChecksumAlgorithm
+	listed     bool
+}
+
+// files reports if the URL is of the artifact level metadata or one of its checksums
+func (l *listing) files(u *url.URL) bool {
+	if l == nil {
+		return false
+	}
+	mdURL := l.url + metadata.MavenMetadataFile
+	if u.String() == mdURL {
+		return true
+	}
+	for _, alg := range l.algorithms {
+		if u.String() == mdURL+"."+string(alg) {
+			return true
+		}
+	}
+	return false
+}
+
+// list records the version of the coordinates being added to the artifact level metadata in the directory at
+// artifactURL, and whether it is already listed. Nothing is recorded if the transaction is nil.
+func (tx *transaction) list(ctx context.Context, repoURL string, c repo.Coordinates, artifactURL string, algorithms []repo.ChecksumAlgorithm, cl *http.Client) error {
+	if tx == nil {
+		return nil
+	}
+	// only the listing matters so the checksums are not checked
+	md, err := metadata.GetContext(ctx, repoURL, c.GroupID, c.ArtifactID, repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyIgnore}, cl)
+	if _, ok := err.(metadata.NotFound); err != nil && !ok {
+		return fmt.Errorf("could not read %s before deployment: %v", metadata.URL(repoURL, c.GroupID, c.ArtifactID), err)
+	}
+	tx.listing = &listing{
+		repoURL:    repoURL,
+		c:          c,
+		url:        artifactURL,
+		algorithms: algorithms,
+		listed:     err == nil && md.HasVersion(c.Version),
+	}
+	return nil
+}
+
+// save records the current content of the file at the URL and of its checksum files before they are overwritten.
+// Files that do not exist are not recorded and so are deleted on roll back. Nothing is saved if the transaction is
+// nil.
+func (tx *transaction) save(ctx context.Context, fileURL string, algorithms []repo.ChecksumAlgorithm, cl *http.Client) error {
+	if tx == nil {
+		return nil
+	}
+	urls := []string{fileURL}
+	for _, alg := range algorithms {
+		urls = append(urls, fileURL+"."+string(alg))
+	}
+	for _, u := range urls {
+		b, ok, err := getFile(ctx, u, cl)
+		if err != nil {
+			return fmt.Errorf("could not save %s before deployment: %v", u, err)
+		}
+		if ok {
+			tx.previous[u] = b
+		}
+	}
+	return nil
+}
+
+// rollback removes the version from the artifact level metadata, unless it was listed before, then removes the other
+// uploaded files, or restores their previous content, in reverse order of upload.
+// The returned error has the ErrorString describing the roll back.
+func (tx *transaction) rollback(ctx context.Context, uploaded []*url.URL, cl *http.Client) RollbackError {
+	var rb RollbackError
+	var failures []string
+	var listed, files []*url.URL
+	for _, u := range uploaded {
+		if tx.listing.files(u) {
+			listed = append(listed, u)
+			continue
+		}
+		files = append(files, u)
+	}
+	switch {
+	case len(listed) == 0:
+	case tx.listing.listed:
+		// the version remains listed as it was before the deployment
+		rb.RolledBack = append(rb.RolledBack, listed...)
+	default:
+		if err := tx.unlist(ctx, cl); err != nil {
+			rb.NotRolledBack = append(rb.NotRolledBack, listed...)
+			failures = append(failures, err.Error())
+		} else {
+			rb.RolledBack = append(rb.RolledBack, listed...)
+		}
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		u := files[i]
+		var err error
+		if b, ok := tx.previous[u.String()]; ok {
+			err = restore(ctx, u, b, cl)
+		} else {
+			err = remove(ctx, u, cl)
+		}
+		if err != nil {
+			rb.NotRolledBack = append(rb.NotRolledBack, u)
+			failures = append(failures, err.Error())
+			continue
+		}
+		rb.RolledBack = append(rb.RolledBack, u)
+	}
+	if len(failures) == 0 {
+		rb.ErrorString = fmt.Sprintf("rolled back %d files", len(rb.RolledBack))
+	} else {
+		rb.ErrorString = fmt.Sprintf("rolled back %d files, %d could not be rolled back: %s", len(rb.RolledBack),
+			len(rb.NotRolledBack), strings.Join(failures, "; "))
+	}
+	return rb
+}
+
+// unlist removes the version from the artifact level metadata, rewriting its checksums, conditional on the metadata
+// not being changed concurrently
+func (tx *transaction) unlist(ctx context.Context, cl *http.Client) error {
+	l := tx.listing
+	_, err := metadata.RemoveContext(ctx, l.repoURL, l.c.GroupID, l.c.ArtifactID, l.c.Version, metadata.DefaultUpdateAttempts,
+		func(ctx context.Context, md metadata.MetaData, pc metadata.Precondition) error {
+			_, err := uploadMetadata(ctx, md, l.url, pc.Header(), l.algorithms, cl)
+			return err
+		}, cl)
+	if err != nil {
+		return fmt.Errorf("error removing version %s from %s: %v", l.c.Version, l.url+metadata.MavenMetadataFile, err)
+	}
+	return nil
+}
+
+// getFile gets the file at the URL. False is returned if it does not exist.
+func getFile(ctx context.Context, fileURL string, cl *http.Client) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("return code %d", resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// restore PUTs the previous content of the file
+func restore(ctx context.Context, u *url.URL, b []byte, cl *http.Client) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", u.String(), bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("could not create request to restore %s: %v", u.String(), err)
+	}
+	return rollbackRequest(req, cl, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+}
+
+// remove DELETEs the file. A file that is already gone counts as removed.
+func remove(ctx context.Context, u *url.URL, cl *http.Client) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("could not create request to delete %s: %v", u.String(), err)
+	}
+	return rollbackRequest(req, cl, http.StatusOK, http.StatusAccepted, http.StatusNoContent, http.StatusNotFound)
+}
+
+func rollbackRequest(req *http.Request, cl *http.Client, codes ...int) error {
+	resp, err := cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("error rolling back %s: %v", req.URL.String(), err)
+	}
+	resp.Body.Close()
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s: return code %d", req.Method, req.URL.String(), resp.StatusCode)
+}
@@ -0,0 +1,197 @@
+package deployfile
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/jcmturner/gomvn/metadata"
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingServer passes requests to the repository server except those for which fail returns true, which fail with an
+// internal server error
+func failingServer(rs *repoServer, fail func(r *http.Request) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail(r) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rs.Config.Handler.ServeHTTP(w, r)
+	}))
+}
+
+func TestUploadAllTransactional(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	const mdPath = "/com/example/example/maven-metadata.xml"
+	s := failingServer(rs, func(r *http.Request) bool {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		return r.Method == http.MethodPut && r.URL.Path == mdPath && rs.puts[mdPath] > 0
+	})
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.0"}
+	a := []Attachment{{Classifier: "sources", File: file.Name()}}
+
+	_, err = UploadAllTransactional(s.URL, c, file.Name(), a, nil, testUsername, testPassword, nil)
+	if err != nil {
+		t.Fatalf("error uploading 1.0: %v", err)
+	}
+	prevMD, _ := rs.file(mdPath)
+	prevSHA1, _ := rs.file(mdPath + ".sha1")
+
+	// the second deployment fails updating the metadata
+	c.Version = "1.1"
+	u, err := UploadAllTransactional(s.URL, c, file.Name(), a, nil, testUsername, testPassword, nil)
+	rb, ok := err.(RollbackError)
+	if !ok {
+		t.Fatalf("expected RollbackError, got: %v", err)
+	}
+	assert.NotNil(t, rb.Err)
+	assert.Equal(t, 9, len(rb.RolledBack), "jar, sources and pom with their checksums should be rolled back")
+	assert.Equal(t, 0, len(rb.NotRolledBack))
+	assert.Equal(t, 0, len(u), "no files should remain")
+	assert.Equal(t, "/com/example/example/1.1/example-1.1.pom.md5", rb.RolledBack[0].Path, "not rolled back in reverse order")
+	for _, f := range []string{"example-1.1.jar", "example-1.1-sources.jar", "example-1.1.pom"} {
+		for _, suffix := range []string{"", ".sha1", ".md5"} {
+			if _, ok := rs.file("/com/example/example/1.1/" + f + suffix); ok {
+				t.Errorf("%s%s was not removed", f, suffix)
+			}
+		}
+	}
+	md, _ := rs.file(mdPath)
+	assert.Equal(t, string(prevMD), string(md), "metadata should be unchanged")
+	b, _ := rs.file(mdPath + ".sha1")
+	assert.Equal(t, string(prevSHA1), string(b), "metadata checksum should be unchanged")
+}
+
+func TestUploadAllTransactional_Unlist(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	const mdPath = "/com/example/example/maven-metadata.xml"
+	// the metadata is written by the deployment of 1.1 but its sha1 fails, so 1.1 must be removed from the metadata
+	// rather than the metadata deleted
+	s := failingServer(rs, func(r *http.Request) bool {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		return r.Method == http.MethodPut && r.URL.Path == mdPath+".sha1" && rs.puts[mdPath] == 2
+	})
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.0"}
+	_, err = UploadAllTransactional(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	if err != nil {
+		t.Fatalf("error uploading 1.0: %v", err)
+	}
+
+	// another deployment lists 1.2 just after 1.1 is listed, which must be kept
+	rs.onPut = func(path string) {
+		if path == mdPath && rs.puts[mdPath] == 2 {
+			var md metadata.MetaData
+			md.Unmarshal(rs.files[mdPath])
+			md.AddVersion("1.2")
+			rs.files[mdPath], _ = md.Marshal()
+		}
+	}
+	c.Version = "1.1"
+	_, err = UploadAllTransactional(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	rb, ok := err.(RollbackError)
+	if !ok {
+		t.Fatalf("expected RollbackError, got: %v", err)
+	}
+	assert.Equal(t, 0, len(rb.NotRolledBack))
+	assert.Equal(t, mdPath, rb.RolledBack[0].Path, "version should be removed from the metadata first")
+	md, err := metadata.Get(rs.URL, "com.example", "example", nil)
+	if err != nil {
+		t.Fatalf("error getting metadata: %v", err)
+	}
+	assert.Equal(t, []string{"1.0", "1.2"}, md.Versioning.Versions.String())
+	b, _ := rs.file(mdPath)
+	sum := sha1.Sum(b)
+	sha1b, _ := rs.file(mdPath + ".sha1")
+	assert.Equal(t, hex.EncodeToString(sum[:]), string(sha1b))
+}
+
+func TestUploadAllTransactional_Listed(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	const mdPath = "/com/example/example/maven-metadata.xml"
+	// redeploying 1.0 fails writing the metadata's sha1, which must leave 1.0 listed
+	s := failingServer(rs, func(r *http.Request) bool {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		return r.Method == http.MethodPut && r.URL.Path == mdPath+".sha1" && rs.puts[mdPath] == 2
+	})
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.0"}
+	for i := 0; i < 2; i++ {
+		_, err = UploadAllTransactional(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	}
+	if _, ok := err.(RollbackError); !ok {
+		t.Fatalf("expected RollbackError, got: %v", err)
+	}
+	md, err := metadata.GetWithOptions(rs.URL, "com.example", "example", repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyIgnore}, nil)
+	if err != nil {
+		t.Fatalf("error getting metadata: %v", err)
+	}
+	assert.Equal(t, []string{"1.0"}, md.Versioning.Versions.String(), "version listed before the deployment should be kept")
+}
+
+func TestUploadAllTransactional_NotRolledBack(t *testing.T) {
+	rs := newRepoServer()
+	defer rs.Close()
+	const pomPath = "/com/example/example/1.0/example-1.0.pom"
+	// the metadata cannot be written and the pom cannot be deleted
+	s := failingServer(rs, func(r *http.Request) bool {
+		return (r.Method == http.MethodPut && r.URL.Path == "/com/example/example/maven-metadata.xml") ||
+			(r.Method == http.MethodDelete && r.URL.Path == pomPath)
+	})
+	defer s.Close()
+
+	file, err := ioutil.TempFile(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("mockartifact")
+	c := repo.Coordinates{GroupID: "com.example", ArtifactID: "example", Version: "1.0"}
+	u, err := UploadAllTransactional(s.URL, c, file.Name(), nil, nil, testUsername, testPassword, nil)
+	rb, ok := err.(RollbackError)
+	if !ok {
+		t.Fatalf("expected RollbackError, got: %v", err)
+	}
+	assert.Equal(t, 5, len(rb.RolledBack))
+	if assert.Equal(t, 1, len(rb.NotRolledBack)) {
+		assert.Equal(t, pomPath, rb.NotRolledBack[0].Path)
+	}
+	assert.Equal(t, rb.NotRolledBack, u, "the files not rolled back should be returned")
+	_, ok = rs.file(pomPath)
+	assert.True(t, ok)
+	assert.Contains(t, err.Error(), pomPath)
+}
@@ -0,0 +1,170 @@
+package getfile
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/jcmturner/gomvn/metadata"
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/jcmturner/gomvn/version"
+)
+
+// Download streams the artifact file identified by the coordinates to dest, verifying it against the checksum published in the repository.
+// The checksum is only known to match once Download returns without error, so anything written to dest should be
+// discarded on error. A -SNAPSHOT version is resolved to the timestamped file of the latest deployment.
+// The URL of the file downloaded is returned.
+func Download(ctx context.Context, repoURL string, c repo.Coordinates, dest io.Writer, cl *http.Client) (*url.URL, error) {
+	return DownloadWithOptions(ctx, repoURL, c, dest, repo.FetchOptions{}, cl)
+}
+
+// DownloadWithOptions downloads the artifact to dest as Download does, verifying the checksums according to the options.
+func DownloadWithOptions(ctx context.Context, repoURL string, c repo.Coordinates, dest io.Writer, opts repo.FetchOptions, cl *http.Client) (*url.URL, error) {
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid coordinates: %v", err)
+	}
+	u, err := artifactURL(ctx, repoURL, c, opts, cl)
+	if err != nil {
+		return u, err
+	}
+	return u, get(ctx, u, dest, opts, cl)
+}
+
+// DownloadFile downloads the artifact to the file at path. The file is only created once the download has been
+// verified against the checksum published in the repository.
+func DownloadFile(ctx context.Context, repoURL string, c repo.Coordinates, path string, cl *http.Client) (*url.URL, error) {
+	return DownloadFileWithOptions(ctx, repoURL, c, path, repo.FetchOptions{}, cl)
+}
+
+// DownloadFileWithOptions downloads the artifact to the file at path as DownloadFile does, verifying the checksums
+// according to the options.
+func DownloadFileWithOptions(ctx context.Context, repoURL string, c repo.Coordinates, path string, opts repo.FetchOptions, cl *http.Client) (*url.URL, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("could not create file to download to: %v", err)
+	}
+	defer os.Remove(f.Name())
+	u, err := DownloadWithOptions(ctx, repoURL, c, f, opts, cl)
+	if err != nil {
+		f.Close()
+		return u, err
+	}
+	err = f.Close()
+	if err != nil {
+		return u, fmt.Errorf("error writing downloaded file: %v", err)
+	}
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return u, fmt.Errorf("could not move downloaded file to %s: %v", path, err)
+	}
+	return u, nil
+}
+
+// Snapshot downloads the file of a -SNAPSHOT version with the classifier and extension to w.
+// The timestamped file name is found from the version level metadata. If there is no version level metadata the
+// non-unique file name is downloaded. The URL of the file downloaded is returned.
+func Snapshot(repoURL, groupID, artifactID, classifier, extension, snapshotVersion string, w io.Writer, cl *http.Client) (*url.URL, error) {
+	return SnapshotContext(context.Background(), repoURL, groupID, artifactID, classifier, extension, snapshotVersion, w, cl)
+}
+
+// SnapshotContext downloads the file of a -SNAPSHOT version to w with the context as Snapshot does.
+func SnapshotContext(ctx context.Context, repoURL, groupID, artifactID, classifier, extension, snapshotVersion string, w io.Writer, cl *http.Client) (*url.URL, error) {
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+	c := repo.Coordinates{
+		GroupID:    groupID,
+		ArtifactID: artifactID,
+		Extension:  extension,
+		Classifier: classifier,
+		Version:    snapshotVersion,
+	}
+	u, err := artifactURL(ctx, repoURL, c, repo.FetchOptions{}, cl)
+	if err != nil {
+		return u, err
+	}
+	return u, get(ctx, u, w, repo.FetchOptions{}, cl)
+}
+
+// artifactURL returns the URL of the artifact file, resolving the timestamped file of a -SNAPSHOT version
+func artifactURL(ctx context.Context, repoURL string, c repo.Coordinates, opts repo.FetchOptions, cl *http.Client) (*url.URL, error) {
+	fileVersion := c.Version
+	v, err := version.New(c.Version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version: %v", err)
+	}
+	if ts, err := version.ParseTimestamped(c.Version); err == nil {
+		// a timestamped file is in the directory of its -SNAPSHOT version
+		c.Version = ts.BaseVersion.String()
+	} else if v.IsSnapshot() {
+		md, err := metadata.GetVersionContext(ctx, repoURL, c.GroupID, c.ArtifactID, c.Version, opts, cl)
+		if err != nil {
+			if _, ok := err.(metadata.NotFound); !ok {
+				return nil, fmt.Errorf("error getting snapshot version metadata: %v", err)
+			}
+		} else if v, ok := md.SnapshotValue(c.Classifier, c.Extension); ok {
+			fileVersion = v
+		}
+	}
+	u, err := url.Parse(c.VersionURL(repoURL) + c.FileNameForVersion(fileVersion))
+	if err != nil {
+		return nil, fmt.Errorf("URL for artifact not valid: %v", err)
+	}
+	return u, nil
+}
+
+// get streams the file at the URL to w, verifying it against the strongest remote checksum once fully read.
+// The checksum policy of the options is applied to a missing or mismatched checksum.
+func get(ctx context.Context, u *url.URL, w io.Writer, opts repo.FetchOptions, cl *http.Client) error {
+	var alg repo.ChecksumAlgorithm
+	var expected string
+	if opts.ChecksumPolicy != repo.ChecksumPolicyIgnore {
+		var err error
+		alg, expected, err = repo.StrongestChecksum(ctx, u.String(), cl)
+		if err = opts.Check(u.String(), err); err != nil {
+			return fmt.Errorf("integrity check failed: %v", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("error forming request of %s: %v", u, err)
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("error getting %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http response %d downloading %s", resp.StatusCode, u)
+	}
+	if expected == "" {
+		// not verifying the checksum
+		_, err = io.Copy(w, resp.Body)
+		if err != nil {
+			return fmt.Errorf("error downloading %s: %v", u, err)
+		}
+		return nil
+	}
+	h := alg.Hash()
+	_, err = io.Copy(io.MultiWriter(w, h), resp.Body)
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %v", u, err)
+	}
+	got := hex.EncodeToString(h.Sum(nil))
+	if got != expected {
+		err = fmt.Errorf("%s checksum of %s does not match. expected: %s got: %s", alg, u, expected, got)
+		if err = opts.Check(u.String(), err); err != nil {
+			return fmt.Errorf("integrity check failed: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package getfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	versionMetaData = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata modelVersion="1.1.0">
+  <groupId>com.example</groupId>
+  <artifactId>example</artifactId>
+  <version>1.0-SNAPSHOT</version>
+  <versioning>
+    <snapshot>
+      <timestamp>20201012.101112</timestamp>
+      <buildNumber>3</buildNumber>
+    </snapshot>
+    <lastUpdated>20201012101112</lastUpdated>
+    <snapshotVersions>
+      <snapshotVersion>
+        <extension>jar</extension>
+        <value>1.0-20201012.101112-3</value>
+        <updated>20201012101112</updated>
+      </snapshotVersion>
+      <snapshotVersion>
+        <classifier>sources</classifier>
+        <extension>jar</extension>
+        <value>1.0-20201012.101010-2</value>
+        <updated>20201012101010</updated>
+      </snapshotVersion>
+    </snapshotVersions>
+  </versioning>
+</metadata>
+`
+	legacyVersionMetaData = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata>
+  <groupId>com.example</groupId>
+  <artifactId>legacy</artifactId>
+  <version>1.0-SNAPSHOT</version>
+  <versioning>
+    <snapshot>
+      <timestamp>20201012.101112</timestamp>
+      <buildNumber>5</buildNumber>
+    </snapshot>
+    <lastUpdated>20201012101112</lastUpdated>
+  </versioning>
+</metadata>
+`
+)
+
+// testServer serves the files provided along with sha1 checksums of them
+func testServer(files map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if b, ok := files[r.URL.Path]; ok {
+			w.Write([]byte(b))
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".sha1") {
+			b, ok := files[strings.TrimSuffix(r.URL.Path, ".sha1")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			h := sha1.Sum([]byte(b))
+			w.Write([]byte(hex.EncodeToString(h[:])))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestSnapshot(t *testing.T) {
+	s := testServer(map[string]string{
+		"/com/example/example/1.0-SNAPSHOT/maven-metadata.xml":                        versionMetaData,
+		"/com/example/example/1.0-SNAPSHOT/example-1.0-20201012.101112-3.jar":         "build3",
+		"/com/example/example/1.0-SNAPSHOT/example-1.0-20201012.101010-2-sources.jar": "sources2",
+		"/com/example/legacy/1.0-SNAPSHOT/maven-metadata.xml":                         legacyVersionMetaData,
+		"/com/example/legacy/1.0-SNAPSHOT/legacy-1.0-20201012.101112-5.jar":           "legacy5",
+		"/com/example/nometa/1.0-SNAPSHOT/nometa-1.0-SNAPSHOT.jar":                    "nonunique",
+	})
+	defer s.Close()
+
+	tests := []struct {
+		artifactID string
+		classifier string
+		expected   string
+		path       string
+	}{
+		{"example", "", "build3", "/com/example/example/1.0-SNAPSHOT/example-1.0-20201012.101112-3.jar"},
+		{"example", "sources", "sources2", "/com/example/example/1.0-SNAPSHOT/example-1.0-20201012.101010-2-sources.jar"},
+		{"legacy", "", "legacy5", "/com/example/legacy/1.0-SNAPSHOT/legacy-1.0-20201012.101112-5.jar"},
+		{"nometa", "", "nonunique", "/com/example/nometa/1.0-SNAPSHOT/nometa-1.0-SNAPSHOT.jar"},
+	}
+	for _, test := range tests {
+		b := new(bytes.Buffer)
+		u, err := Snapshot(s.URL, "com.example", test.artifactID, test.classifier, "jar", "1.0-SNAPSHOT", b, nil)
+		if err != nil {
+			t.Errorf("error downloading %s snapshot: %v", test.artifactID, err)
+			continue
+		}
+		assert.Equal(t, test.expected, b.String())
+		assert.Equal(t, s.URL+test.path, u.String())
+	}
+}
+
+func TestSnapshot_NotFound(t *testing.T) {
+	s := testServer(map[string]string{
+		"/com/example/example/1.0-SNAPSHOT/maven-metadata.xml": versionMetaData,
+	})
+	defer s.Close()
+	b := new(bytes.Buffer)
+	_, err := Snapshot(s.URL, "com.example", "example", "", "jar", "1.0-SNAPSHOT", b, nil)
+	assert.NotNil(t, err, "download of missing file should error")
+	assert.Equal(t, 0, b.Len(), "nothing should be written when the download fails")
+}
+
+func coordinates(t *testing.T, s string) repo.Coordinates {
+	c, err := repo.NewCoordinates(s)
+	if err != nil {
+		t.Fatalf("invalid coordinates: %v", err)
+	}
+	return c
+}
+
+func TestDownload(t *testing.T) {
+	s := testServer(map[string]string{
+		"/com/example/example/1.0/example-1.0.jar":                            "release",
+		"/com/example/example/1.0-SNAPSHOT/maven-metadata.xml":                versionMetaData,
+		"/com/example/example/1.0-SNAPSHOT/example-1.0-20201012.101112-3.jar": "build3",
+		"/com/example/example/1.1/example-1.1.jar":                            "tampered",
+		"/com/example/example/1.1/example-1.1.jar.sha1":                       "0000000000000000000000000000000000000000",
+	})
+	defer s.Close()
+
+	b := new(bytes.Buffer)
+	u, err := Download(context.Background(), s.URL, coordinates(t, "com.example:example:1.0"), b, nil)
+	if err != nil {
+		t.Fatalf("error downloading: %v", err)
+	}
+	assert.Equal(t, "release", b.String())
+	assert.Equal(t, s.URL+"/com/example/example/1.0/example-1.0.jar", u.String())
+
+	b.Reset()
+	_, err = Download(context.Background(), s.URL, coordinates(t, "com.example:example:1.0-SNAPSHOT"), b, nil)
+	if err != nil {
+		t.Fatalf("error downloading snapshot: %v", err)
+	}
+	assert.Equal(t, "build3", b.String())
+
+	b.Reset()
+	u, err = Download(context.Background(), s.URL, coordinates(t, "com.example:example:1.0-20201012.101112-3"), b, nil)
+	if err != nil {
+		t.Fatalf("error downloading timestamped snapshot: %v", err)
+	}
+	assert.Equal(t, "build3", b.String())
+	assert.Equal(t, s.URL+"/com/example/example/1.0-SNAPSHOT/example-1.0-20201012.101112-3.jar", u.String())
+
+	_, err = Download(context.Background(), s.URL, coordinates(t, "com.example:example:1.1"), new(bytes.Buffer), nil)
+	assert.NotNil(t, err, "download with invalid checksum should error")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Download(ctx, s.URL, coordinates(t, "com.example:example:1.0"), new(bytes.Buffer), nil)
+	assert.NotNil(t, err, "download with cancelled context should error")
+}
+
+func TestDownloadWithOptions(t *testing.T) {
+	s := testServer(map[string]string{
+		"/com/example/example/1.1/example-1.1.jar":      "tampered",
+		"/com/example/example/1.1/example-1.1.jar.sha1": "0000000000000000000000000000000000000000",
+	})
+	defer s.Close()
+	c := coordinates(t, "com.example:example:1.1")
+
+	_, err := DownloadWithOptions(context.Background(), s.URL, c, new(bytes.Buffer), repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyFail}, nil)
+	assert.NotNil(t, err, "download with invalid checksum should error with fail policy")
+
+	logs := new(bytes.Buffer)
+	b := new(bytes.Buffer)
+	opts := repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyWarn, Logger: log.New(logs, "", 0)}
+	_, err = DownloadWithOptions(context.Background(), s.URL, c, b, opts, nil)
+	if err != nil {
+		t.Fatalf("error downloading with warn policy: %v", err)
+	}
+	assert.Equal(t, "tampered", b.String())
+	assert.Contains(t, logs.String(), "example-1.1.jar", "warning not logged")
+
+	b.Reset()
+	_, err = DownloadWithOptions(context.Background(), s.URL, c, b, repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyIgnore}, nil)
+	if err != nil {
+		t.Fatalf("error downloading with ignore policy: %v", err)
+	}
+	assert.Equal(t, "tampered", b.String())
+}
+
+func TestDownloadFile(t *testing.T) {
+	s := testServer(map[string]string{
+		"/com/example/example/1.0/example-1.0.jar":      "release",
+		"/com/example/example/1.1/example-1.1.jar":      "tampered",
+		"/com/example/example/1.1/example-1.1.jar.sha1": "0000000000000000000000000000000000000000",
+	})
+	defer s.Close()
+	dir, err := ioutil.TempDir(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "example.jar")
+	_, err = DownloadFile(context.Background(), s.URL, coordinates(t, "com.example:example:1.0"), path, nil)
+	if err != nil {
+		t.Fatalf("error downloading: %v", err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read downloaded file: %v", err)
+	}
+	assert.Equal(t, "release", string(b))
+
+	path = filepath.Join(dir, "tampered.jar")
+	_, err = DownloadFile(context.Background(), s.URL, coordinates(t, "com.example:example:1.1"), path, nil)
+	assert.NotNil(t, err, "download with invalid checksum should error")
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "file should not exist after failed download")
+	fs, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 1, len(fs), "temporary files should be removed")
+}
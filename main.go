@@ -4,10 +4,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 
 	"github.com/jcmturner/gomvn/deployfile"
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/jcmturner/gomvn/settings"
 )
 
 func main() {
@@ -19,14 +22,21 @@ func main() {
 	file := flag.String("file", "", "file to upload")
 	username := flag.String("username", "", "username for authentication to the repository")
 	password := flag.String("password", "", "password for authentication to the repository")
+	server := flag.String("server", "", "id of the server in the maven settings to authenticate to the repository with")
+	settingsPath := flag.String("settings", "", "path to the maven settings (default ~/.m2/settings.xml)")
+	retries := flag.Int("retries", repo.DefaultRetryPolicy.Attempts-1, "number of times to retry a request failing with a transient error")
+	rollback := flag.Bool("rollback", false, "remove the files already uploaded if the deployment fails")
 	flag.Parse()
 
-	//Check all the flags has a value
-	flag.VisitAll(func(f *flag.Flag) {
-		if f.Value.String() == "" {
-			log.Fatalf("error: %s not defined", f.Name)
+	//Check all the required flags has a value
+	for _, name := range []string{"repourl", "group", "artifact", "ext", "version", "file"} {
+		if flag.Lookup(name).Value.String() == "" {
+			log.Fatalf("error: %s not defined", name)
 		}
-	})
+	}
+	if *server == "" && (*username == "" || *password == "") {
+		log.Fatalln("error: either server or username and password must be defined")
+	}
 
 	//Check the repourl is a valid URL
 	u, err := url.Parse(*repourl)
@@ -37,8 +47,61 @@ func main() {
 		log.Fatalln("repourl neither http nor https")
 	}
 
+	var cl *http.Client
+	if *server != "" {
+		if *settingsPath == "" {
+			*settingsPath, err = settings.DefaultPath()
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		s, err := settings.Load(*settingsPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if srv, ok := s.Server(*server); ok && settings.IsEncrypted(srv.Password) {
+			secPath, err := settings.DefaultSecurityPath()
+			if err != nil {
+				log.Fatalln(err)
+			}
+			sec, err := settings.LoadSecurity(secPath)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if err := s.DecryptPasswords(sec); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		auth, err := s.Credentials(*server)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		// as with maven the proxies of the settings are used for deployment but not the mirrors
+		cl, err = repo.NewAuthClient(*repourl, auth, &http.Client{Transport: s.Transport()})
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if *retries > 0 {
+		p := repo.DefaultRetryPolicy
+		p.Attempts = *retries + 1
+		cl = repo.NewRetryClient(p, cl)
+	}
+
 	log.Println("uploading artifact...")
-	us, err := deployfile.Upload(*repourl, *group, *artifact, *pkg, *version, *file, *username, *password, nil)
+	var us []*url.URL
+	if *rollback {
+		c := repo.Coordinates{GroupID: *group, ArtifactID: *artifact, Extension: *pkg, Version: *version}
+		us, err = deployfile.UploadAllTransactional(*repourl, c, *file, nil, nil, *username, *password, cl)
+		if rb, ok := err.(deployfile.RollbackError); ok {
+			for _, u := range rb.NotRolledBack {
+				log.Printf("not rolled back: %s\n", u.String())
+			}
+		}
+	} else {
+		us, err = deployfile.Upload(*repourl, *group, *artifact, *pkg, *version, *file, *username, *password, cl)
+	}
 	if err != nil {
 		log.Fatalf("error uploading: %v\n", err)
 	}
@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
@@ -30,22 +31,22 @@ type MetaData struct {
 }
 
 type Versioning struct {
-	Latest           *version.Version  `xml:"latest,omitempty"`
-	Release          *version.Version  `xml:"release,omitempty"`
-	Snapshot         *Snapshot         `xml:"snapshot,omitempty"`
-	Versions         *version.Versions `xml:"versions>version"`
-	LastUpdated      *TimeStamp        `xml:"lastUpdated"`
-	SnapshotVersions []SnapshotVersion `xml:"snapshotVersions,omitempty"`
+	Latest           *version.Version   `xml:"latest,omitempty"`
+	Release          *version.Version   `xml:"release,omitempty"`
+	Snapshot         *Snapshot          `xml:"snapshot,omitempty"`
+	Versions         *version.Versions  `xml:"versions>version"`
+	LastUpdated      *TimeStamp         `xml:"lastUpdated"`
+	SnapshotVersions *[]SnapshotVersion `xml:"snapshotVersions>snapshotVersion,omitempty"`
 }
 
 type Snapshot struct {
-	TimeStamp   TimeStamp `xml:"timestamp"`
-	BuildNumber int       `xml:"buildNumber"`
-	LocalCopy   bool      `xml:"localCopy"`
+	TimeStamp   SnapshotTimeStamp `xml:"timestamp"`
+	BuildNumber int               `xml:"buildNumber"`
+	LocalCopy   bool              `xml:"localCopy,omitempty"`
 }
 
 type SnapshotVersion struct {
-	Classifier string `xml:"classifier"`
+	Classifier string `xml:"classifier,omitempty"`
 	Extension  string `xml:"extension"`
 	Value      string `xml:"value"`
 	Updated    string `xml:"updated"`
@@ -55,6 +56,11 @@ type TimeStamp struct {
 	time.Time
 }
 
+// SnapshotTimeStamp is the timestamp of a snapshot deployment which has a different layout to lastUpdated.
+type SnapshotTimeStamp struct {
+	time.Time
+}
+
 func New(groupID, artifactID string) MetaData {
 	return MetaData{
 		ModelVersion: modelVersion,
@@ -80,6 +86,28 @@ func (t *TimeStamp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
+func (t *SnapshotTimeStamp) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(t.Format(version.SnapshotTimestampLayout), start)
+}
+
+func (t *SnapshotTimeStamp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var s string
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	tt, err := time.Parse(version.SnapshotTimestampLayout, s)
+	if err != nil {
+		return err
+	}
+	*t = SnapshotTimeStamp{tt}
+	return nil
+}
+
+// Timestamped returns the timestamped version of the snapshot for the -SNAPSHOT base version.
+func (s Snapshot) Timestamped(base version.Version) (version.Timestamped, error) {
+	return version.NewTimestamped(base, s.TimeStamp.Time, s.BuildNumber)
+}
+
 func (m *MetaData) Marshal() ([]byte, error) {
 	b := []byte(xml.Header)
 	mb, err := xml.MarshalIndent(m, "", "  ")
@@ -97,7 +125,9 @@ func (m *MetaData) Unmarshal(b []byte) error {
 	if err != nil {
 		return fmt.Errorf("error unmarshaling metadata: %v", err)
 	}
-	sort.Sort(m.Versioning.Versions)
+	if m.Versioning.Versions != nil {
+		sort.Sort(m.Versioning.Versions)
+	}
 	return nil
 }
 
@@ -110,11 +140,56 @@ func (e NotFound) Error() string {
 }
 
 func Get(repoURL, groupID, artifactID string, cl *http.Client) (md MetaData, err error) {
+	return GetContext(context.Background(), repoURL, groupID, artifactID, repo.FetchOptions{}, cl)
+}
+
+// GetWithOptions gets the artifact level metadata, verifying it according to the options.
+func GetWithOptions(repoURL, groupID, artifactID string, opts repo.FetchOptions, cl *http.Client) (md MetaData, err error) {
+	return GetContext(context.Background(), repoURL, groupID, artifactID, opts, cl)
+}
+
+// GetContext gets the artifact level metadata with the context, verifying it according to the options.
+func GetContext(ctx context.Context, repoURL, groupID, artifactID string, opts repo.FetchOptions, cl *http.Client) (md MetaData, err error) {
+	return get(ctx, URL(repoURL, groupID, artifactID), opts, cl)
+}
+
+// GetVersion gets the version level metadata of a SNAPSHOT version
+func GetVersion(repoURL, groupID, artifactID, snapshotVersion string, cl *http.Client) (md MetaData, err error) {
+	return GetVersionContext(context.Background(), repoURL, groupID, artifactID, snapshotVersion, repo.FetchOptions{}, cl)
+}
+
+// GetVersionWithOptions gets the version level metadata of a SNAPSHOT version, verifying it according to the options.
+func GetVersionWithOptions(repoURL, groupID, artifactID, snapshotVersion string, opts repo.FetchOptions, cl *http.Client) (md MetaData, err error) {
+	return GetVersionContext(context.Background(), repoURL, groupID, artifactID, snapshotVersion, opts, cl)
+}
+
+// GetVersionContext gets the version level metadata of a SNAPSHOT version with the context, verifying it according
+// to the options.
+func GetVersionContext(ctx context.Context, repoURL, groupID, artifactID, snapshotVersion string, opts repo.FetchOptions, cl *http.Client) (md MetaData, err error) {
+	return get(ctx, VersionURL(repoURL, groupID, artifactID, snapshotVersion), opts, cl)
+}
+
+// URL returns the location of the artifact level metadata
+func URL(repoURL, groupID, artifactID string) string {
 	groupPath := strings.Join(strings.Split(groupID, "."), "/")
-	url := fmt.Sprintf("%s/%s/%s/%s", strings.TrimRight(repoURL, "/"), groupPath, artifactID, MavenMetadataFile)
+	return fmt.Sprintf("%s/%s/%s/%s", strings.TrimRight(repoURL, "/"), groupPath, artifactID, MavenMetadataFile)
+}
+
+// VersionURL returns the location of the version level metadata of a SNAPSHOT version
+func VersionURL(repoURL, groupID, artifactID, snapshotVersion string) string {
+	_, versionURL, _ := repo.ParseCoordinates(repoURL, groupID, artifactID, "", snapshotVersion)
+	return versionURL + MavenMetadataFile
+}
+
+func get(ctx context.Context, url string, opts repo.FetchOptions, cl *http.Client) (md MetaData, err error) {
+	md, _, err = fetch(ctx, url, opts, cl)
+	return
+}
 
+// fetch gets the metadata at the URL along with its ETag, which is empty if the repository does not return one
+func fetch(ctx context.Context, url string, opts repo.FetchOptions, cl *http.Client) (md MetaData, etag string, err error) {
 	// Get the metadata
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		err = fmt.Errorf("error forming request of %s: %v", url, err)
 		return
@@ -127,6 +202,7 @@ func Get(repoURL, groupID, artifactID string, cl *http.Client) (md MetaData, err
 		err = fmt.Errorf("error getting %s: %v", url, err)
 		return
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusNotFound {
 		err = NotFound{
 			ErrorString: fmt.Sprintf("http response %d downloading metadata (%s)", resp.StatusCode, url),
@@ -142,45 +218,251 @@ func Get(repoURL, groupID, artifactID string, cl *http.Client) (md MetaData, err
 		err = fmt.Errorf("error reading body from %s: %v", url, err)
 		return
 	}
-	resp.Body.Close()
 
-	ok, err := repo.SHA1(url, mb, cl)
-	if !ok || err != nil {
-		err = fmt.Errorf("integrity check failed: %v", err)
+	err = opts.Verify(ctx, url, mb, cl)
+	if err != nil {
+		// keep the type of a checksum error so that it can be told apart
+		switch e := err.(type) {
+		case repo.ChecksumNotFound:
+			e.ErrorString = "integrity check failed: " + e.ErrorString
+			err = e
+		case repo.ChecksumMismatch:
+			e.ErrorString = "integrity check failed: " + e.ErrorString
+			err = e
+		default:
+			err = fmt.Errorf("integrity check failed: %v", err)
+		}
 		return
 	}
 
 	// unmarshal bytes into MetaData type
 	err = md.Unmarshal(mb)
+	etag = resp.Header.Get("ETag")
 	return
 }
 
-func Generate(repo, groupID, artifactID, newVersion string, cl *http.Client) (MetaData, error) {
-	var md MetaData
+// Resolve returns the highest version of the artifact in the repository that is within the range spec.
+// SNAPSHOT versions are only considered if snapshots is true.
+// As with maven a soft requirement (eg "1.0") is used as is, without consulting the repository.
+// A version.NoMatch error is returned if no version in the repository is within the range.
+func Resolve(repoURL, groupID, artifactID, rangeSpec string, snapshots bool, cl *http.Client) (version.Version, error) {
+	return ResolveContext(context.Background(), repoURL, groupID, artifactID, rangeSpec, snapshots, cl)
+}
+
+// ResolveContext returns the highest version of the artifact within the range spec as Resolve does, getting the
+// metadata with the context.
+func ResolveContext(ctx context.Context, repoURL, groupID, artifactID, rangeSpec string, snapshots bool, cl *http.Client) (version.Version, error) {
+	r, err := version.ParseRange(rangeSpec)
+	if err != nil {
+		return version.Version{}, err
+	}
+	if r.IsSoft() {
+		return *r.Recommended, nil
+	}
+	md, err := GetContext(ctx, repoURL, groupID, artifactID, repo.FetchOptions{}, cl)
+	if err != nil {
+		return version.Version{}, err
+	}
+	var vs version.Versions
+	if md.Versioning.Versions != nil {
+		for _, v := range *md.Versioning.Versions {
+			if !snapshots && v.IsSnapshot() {
+				continue
+			}
+			vs = append(vs, v)
+		}
+	}
+	v, err := vs.Highest(r)
+	if err != nil {
+		return v, version.NoMatch{
+			ErrorString: fmt.Sprintf("no version of %s:%s within %s", groupID, artifactID, rangeSpec),
+		}
+	}
+	return v, nil
+}
+
+func Generate(repoURL, groupID, artifactID, newVersion string, cl *http.Client) (MetaData, error) {
+	return GenerateContext(context.Background(), repoURL, groupID, artifactID, newVersion, repo.FetchOptions{}, cl)
+}
+
+// GenerateContext generates the artifact level metadata with the new version as Generate does, getting the current
+// metadata with the context and verifying it according to the options.
+func GenerateContext(ctx context.Context, repoURL, groupID, artifactID, newVersion string, opts repo.FetchOptions, cl *http.Client) (MetaData, error) {
+	md, _, err := generate(ctx, repoURL, groupID, artifactID, newVersion, opts, cl)
+	return md, err
+}
+
+// generate gets the current artifact level metadata, verified according to the options, and adds the new version to
+// it. The precondition for writing the metadata back without overwriting a concurrent change is also returned.
+func generate(ctx context.Context, repoURL, groupID, artifactID, newVersion string, opts repo.FetchOptions, cl *http.Client) (MetaData, Precondition, error) {
+	var pc Precondition
 	// Get the current hosted metadata
-	md, err := Get(repo, groupID, artifactID, cl)
+	md, etag, err := fetch(ctx, URL(repoURL, groupID, artifactID), opts, cl)
 	if err != nil {
-		if _, ok := err.(NotFound); ok {
+		switch err.(type) {
+		case NotFound:
 			// No current metadata so create a new one
 			md = New(groupID, artifactID)
-			md.Versioning = Versioning{
-				Versions: new(version.Versions),
-			}
-		} else {
-			return md, fmt.Errorf("error getting existing metadata: %v", err)
+		case repo.ChecksumNotFound, repo.ChecksumMismatch:
+			return md, pc, err
+		default:
+			return md, pc, fmt.Errorf("error getting existing metadata: %v", err)
 		}
+	} else {
+		pc = Precondition{ETag: etag, Exists: true}
 	}
-	// Add the version, resort and update the latest versions
+	err = md.AddVersion(newVersion)
+	return md, pc, err
+}
+
+// AddVersion lists the version if it is not already, updating the latest and release versions and the last updated
+// timestamp.
+func (m *MetaData) AddVersion(newVersion string) error {
 	nv, err := version.New(newVersion)
 	if err != nil {
-		return md, err
+		return err
 	}
-	*md.Versioning.Versions = append(*md.Versioning.Versions, nv)
-	sort.Sort(md.Versioning.Versions)
-	md.Versioning.Latest = &((*md.Versioning.Versions)[len(*md.Versioning.Versions)-1])
-	md.Versioning.Release = md.Versioning.Latest
-	md.Version = md.Versioning.Latest
+	if m.Versioning.Versions == nil {
+		m.Versioning.Versions = new(version.Versions)
+	}
+	// Add the version if not already listed, resort and update the latest versions
+	if !m.HasVersion(nv.String()) {
+		*m.Versioning.Versions = append(*m.Versioning.Versions, nv)
+	}
+	sort.Sort(m.Versioning.Versions)
+	m.updated()
+	return nil
+}
+
+// RemoveVersion unlists the version, updating the latest and release versions and the last updated timestamp if it
+// was listed.
+func (m *MetaData) RemoveVersion(v string) {
+	if !m.HasVersion(v) {
+		return
+	}
+	var vs version.Versions
+	for _, lv := range *m.Versioning.Versions {
+		if lv.String() != v {
+			vs = append(vs, lv)
+		}
+	}
+	*m.Versioning.Versions = vs
+	m.updated()
+}
+
+// updated sets the latest and release versions from the sorted versions listed, and the last updated timestamp
+func (m *MetaData) updated() {
+	vs := *m.Versioning.Versions
+	m.Versioning.Latest = nil
+	if len(vs) > 0 {
+		m.Versioning.Latest = &vs[len(vs)-1]
+	}
+	// The release is the latest version that is not a SNAPSHOT
+	m.Versioning.Release = nil
+	for i := len(vs) - 1; i >= 0; i-- {
+		if !vs[i].IsSnapshot() {
+			m.Versioning.Release = &vs[i]
+			break
+		}
+	}
+	m.Version = m.Versioning.Latest
 	// Set the last update timestamp
-	md.Versioning.LastUpdated = &TimeStamp{time.Now().UTC()}
-	return md, nil
+	m.Versioning.LastUpdated = &TimeStamp{time.Now().UTC()}
+}
+
+// HasVersion returns if the version is listed in the metadata
+func (m *MetaData) HasVersion(v string) bool {
+	if m.Versioning.Versions == nil {
+		return false
+	}
+	for _, lv := range *m.Versioning.Versions {
+		if lv.String() == v {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateSnapshot creates the version level metadata for a new deployment of the SNAPSHOT version at time t.
+// The build number follows on from that of the version level metadata currently in the repository.
+// The timestamped version the files of the deployment should be named with is also returned.
+func GenerateSnapshot(repoURL, groupID, artifactID, snapshotVersion string, t time.Time, cl *http.Client) (MetaData, version.Timestamped, error) {
+	return GenerateSnapshotContext(context.Background(), repoURL, groupID, artifactID, snapshotVersion, t, repo.FetchOptions{}, cl)
+}
+
+// GenerateSnapshotContext creates the version level metadata for a new deployment of the SNAPSHOT version as
+// GenerateSnapshot does, getting the current metadata with the context and verifying it according to the options.
+func GenerateSnapshotContext(ctx context.Context, repoURL, groupID, artifactID, snapshotVersion string, t time.Time, opts repo.FetchOptions, cl *http.Client) (MetaData, version.Timestamped, error) {
+	var ts version.Timestamped
+	base, err := version.New(snapshotVersion)
+	if err != nil {
+		return MetaData{}, ts, err
+	}
+	md, err := GetVersionContext(ctx, repoURL, groupID, artifactID, snapshotVersion, opts, cl)
+	if err != nil {
+		if _, ok := err.(NotFound); !ok {
+			return md, ts, fmt.Errorf("error getting existing version metadata: %v", err)
+		}
+		// No current metadata so create a new one
+		md = New(groupID, artifactID)
+	}
+	md.Version = &base
+	var buildNumber int
+	if md.Versioning.Snapshot != nil {
+		buildNumber = md.Versioning.Snapshot.BuildNumber
+	}
+	ts, err = version.NewTimestamped(base, t, buildNumber+1)
+	if err != nil {
+		return md, ts, err
+	}
+	md.Versioning.Snapshot = &Snapshot{
+		TimeStamp:   SnapshotTimeStamp{ts.Timestamp},
+		BuildNumber: ts.BuildNumber,
+	}
+	md.Versioning.LastUpdated = &TimeStamp{ts.Timestamp}
+	return md, ts, nil
+}
+
+// AddSnapshotVersion records the timestamped file deployed for the classifier and extension,
+// replacing any previous entry for them.
+func (m *MetaData) AddSnapshotVersion(classifier, extension string, ts version.Timestamped) {
+	sv := SnapshotVersion{
+		Classifier: classifier,
+		Extension:  extension,
+		Value:      ts.String(),
+		Updated:    ts.Timestamp.Format(LastUpdatedLayout),
+	}
+	if m.Versioning.SnapshotVersions == nil {
+		m.Versioning.SnapshotVersions = new([]SnapshotVersion)
+	}
+	svs := *m.Versioning.SnapshotVersions
+	for i, e := range svs {
+		if e.Classifier == classifier && e.Extension == extension {
+			svs[i] = sv
+			return
+		}
+	}
+	*m.Versioning.SnapshotVersions = append(svs, sv)
+}
+
+// SnapshotValue returns the timestamped version of the file deployed for the classifier and extension.
+// Metadata without snapshotVersions, as written by maven 2, falls back to the timestamp and build number of the
+// snapshot. False is returned if the metadata does not record a timestamped version.
+func (m *MetaData) SnapshotValue(classifier, extension string) (string, bool) {
+	if m.Versioning.SnapshotVersions != nil {
+		for _, sv := range *m.Versioning.SnapshotVersions {
+			if sv.Classifier == classifier && sv.Extension == extension {
+				return sv.Value, true
+			}
+		}
+	}
+	s := m.Versioning.Snapshot
+	if s == nil || s.LocalCopy || s.TimeStamp.IsZero() || m.Version == nil {
+		return "", false
+	}
+	ts, err := s.Timestamped(*m.Version)
+	if err != nil {
+		return "", false
+	}
+	return ts.String(), true
 }
@@ -2,14 +2,18 @@ package metadata
 
 import (
 	"bytes"
-	"crypto/sha1"
+	"context"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/jcmturner/gomvn/version"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,26 +45,114 @@ const (
   </versioning>
 </metadata>
 `
-	testMetaDataSHA1 = `d290cc8eba0504881f1d165820c27fd7ea5b1d0f`
+	testMetaDataSHA1    = `d290cc8eba0504881f1d165820c27fd7ea5b1d0f`
+	testVersionMetaData = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata modelVersion="1.1.0">
+  <groupId>com.example</groupId>
+  <artifactId>example</artifactId>
+  <version>2.1-SNAPSHOT</version>
+  <versioning>
+    <snapshot>
+      <timestamp>20201012.101112</timestamp>
+      <buildNumber>3</buildNumber>
+    </snapshot>
+    <lastUpdated>20201012101112</lastUpdated>
+  </versioning>
+</metadata>
+`
+	testSnapshotMetaData = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata modelVersion="1.1.0">
+  <groupId>com.example</groupId>
+  <artifactId>example</artifactId>
+  <versioning>
+    <latest>2.1-SNAPSHOT</latest>
+    <release>2.0</release>
+    <versions>
+      <version>1.0</version>
+      <version>1.1</version>
+      <version>1.2-SNAPSHOT</version>
+      <version>1.3-snapshot</version>
+      <version>2.0</version>
+      <version>2.1-SNAPSHOT</version>
+    </versions>
+    <lastUpdated>20201012101112</lastUpdated>
+  </versioning>
+</metadata>
+`
 )
 
+func testServer(md string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, alg := range repo.ChecksumAlgorithms {
+			if strings.HasSuffix(r.RequestURI, "."+string(alg)) {
+				hash := alg.Hash()
+				hash.Write([]byte(md))
+				h := hex.EncodeToString(hash.Sum(nil))
+				fmt.Fprint(w, h+" metadata.xml."+string(alg))
+				return
+			}
+		}
+		fmt.Fprint(w, md)
+	}))
+}
+
 func TestGet(t *testing.T) {
+	ts := testServer(testMetaData)
+	defer ts.Close()
+	md, err := Get(ts.URL, "log4j", "log4j", nil)
+	if err != nil {
+		t.Fatalf("error getting metadata: %v", err)
+	}
+	assert.Equal(t, "1.2.17", md.Versioning.Latest.String())
+}
+
+func TestGetWithOptions(t *testing.T) {
+	// a repository that publishes no checksums
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.RequestURI, ".sha1") {
-			hash := sha1.New()
-			hash.Write([]byte(testMetaData))
-			h := hex.EncodeToString(hash.Sum(nil))
-			fmt.Fprint(w, h+" metadata.xml.sha1")
-		} else {
+		if strings.HasSuffix(r.URL.Path, MavenMetadataFile) {
 			fmt.Fprint(w, testMetaData)
+			return
 		}
+		w.WriteHeader(http.StatusNotFound)
 	}))
 	defer ts.Close()
-	md, err := Get(ts.URL, "log4j", "log4j", nil)
+	_, err := Get(ts.URL, "log4j", "log4j", nil)
+	assert.NotNil(t, err, "get without a checksum should fail by default")
+
+	buf := new(bytes.Buffer)
+	opts := repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyWarn, Logger: log.New(buf, "", 0)}
+	md, err := GetWithOptions(ts.URL, "log4j", "log4j", opts, nil)
+	if err != nil {
+		t.Fatalf("error getting metadata with warn policy: %v", err)
+	}
+	assert.Equal(t, "1.2.17", md.Versioning.Latest.String())
+	assert.Contains(t, buf.String(), "maven-metadata.xml", "warning not logged")
+
+	opts = repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyIgnore}
+	_, err = GetWithOptions(ts.URL, "log4j", "log4j", opts, nil)
+	assert.Nil(t, err, "get with ignore policy should not fail")
+}
+
+func TestGetContext(t *testing.T) {
+	ts := testServer(testMetaData)
+	defer ts.Close()
+	md, err := GetContext(context.Background(), ts.URL, "log4j", "log4j", repo.FetchOptions{}, nil)
 	if err != nil {
 		t.Fatalf("error getting metadata: %v", err)
 	}
 	assert.Equal(t, "1.2.17", md.Versioning.Latest.String())
+
+	// a server that never responds
+	done := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hung.Close()
+	defer close(done)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = GetContext(ctx, hung.URL, "log4j", "log4j", repo.FetchOptions{}, nil)
+	assert.NotNil(t, err, "get should error when the deadline is exceeded")
 }
 
 func TestMarshaling(t *testing.T) {
@@ -77,3 +169,102 @@ func TestMarshaling(t *testing.T) {
 	}
 	assert.Equal(t, eb, mb, "marshaled bytes not as expected")
 }
+
+func TestResolve(t *testing.T) {
+	ts := testServer(testSnapshotMetaData)
+	defer ts.Close()
+	tests := []struct {
+		spec      string
+		snapshots bool
+		expected  string
+	}{
+		{"[1.0,2.0)", false, "1.1"},
+		{"[1.0,2.0)", true, "1.3-snapshot"},
+		{"[1.0,)", false, "2.0"},
+		{"[1.0,)", true, "2.1-SNAPSHOT"},
+		{"(,1.0]", false, "1.0"},
+		{"[1.0]", false, "1.0"},
+		// soft requirements are used as is
+		{"1.5", false, "1.5"},
+	}
+	for _, test := range tests {
+		v, err := Resolve(ts.URL, "com.example", "example", test.spec, test.snapshots, nil)
+		if err != nil {
+			t.Errorf("error resolving %s: %v", test.spec, err)
+			continue
+		}
+		assert.Equal(t, test.expected, v.String(), "resolving %s (snapshots %t)", test.spec, test.snapshots)
+	}
+}
+
+func TestResolve_NoMatch(t *testing.T) {
+	ts := testServer(testSnapshotMetaData)
+	defer ts.Close()
+	for _, spec := range []string{"[3.0,)", "[1.2,1.3)", "(,1.0)"} {
+		_, err := Resolve(ts.URL, "com.example", "example", spec, false, nil)
+		if _, ok := err.(version.NoMatch); !ok {
+			t.Errorf("expected NoMatch error resolving %s, got: %v", spec, err)
+		}
+	}
+}
+
+func TestSnapshot_Timestamped(t *testing.T) {
+	md := new(MetaData)
+	err := md.Unmarshal([]byte(testVersionMetaData))
+	if err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if md.Versioning.Snapshot == nil {
+		t.Fatal("snapshot not unmarshaled")
+	}
+	ts, err := md.Versioning.Snapshot.Timestamped(*md.Version)
+	if err != nil {
+		t.Fatalf("error getting timestamped version: %v", err)
+	}
+	assert.Equal(t, "2.1-20201012.101112-3", ts.String())
+}
+
+func TestGenerateSnapshot(t *testing.T) {
+	ts := testServer(testVersionMetaData)
+	defer ts.Close()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	md, sv, err := GenerateSnapshot(ts.URL, "com.example", "example", "2.1-SNAPSHOT", now, nil)
+	if err != nil {
+		t.Fatalf("error generating snapshot metadata: %v", err)
+	}
+	assert.Equal(t, "2.1-20261016.120000-4", sv.String())
+	md.AddSnapshotVersion("", "jar", sv)
+	md.AddSnapshotVersion("sources", "jar", sv)
+	md.AddSnapshotVersion("", "jar", sv)
+	b, err := md.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<metadata modelVersion="1.1.0">
+  <groupId>com.example</groupId>
+  <artifactId>example</artifactId>
+  <version>2.1-SNAPSHOT</version>
+  <versioning>
+    <snapshot>
+      <timestamp>20261016.120000</timestamp>
+      <buildNumber>4</buildNumber>
+    </snapshot>
+    <lastUpdated>20261016120000</lastUpdated>
+    <snapshotVersions>
+      <snapshotVersion>
+        <extension>jar</extension>
+        <value>2.1-20261016.120000-4</value>
+        <updated>20261016120000</updated>
+      </snapshotVersion>
+      <snapshotVersion>
+        <classifier>sources</classifier>
+        <extension>jar</extension>
+        <value>2.1-20261016.120000-4</value>
+        <updated>20261016120000</updated>
+      </snapshotVersion>
+    </snapshotVersions>
+  </versioning>
+</metadata>`
+	assert.Equal(t, expected, string(b))
+}
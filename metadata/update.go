@@ -0,0 +1,186 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/jcmturner/gomvn/version"
+)
+
+const (
+	// DefaultUpdateAttempts is the number of times Update tries to write the metadata
+	DefaultUpdateAttempts = 5
+	// conflictBackoff is the maximum delay before writing the metadata again after a conflict, scaled by the attempt
+	conflictBackoff = 200 * time.Millisecond
+)
+
+// Conflict is returned when the metadata was changed by another deployment since it was read
+type Conflict struct {
+	ErrorString string
+}
+
+func (e Conflict) Error() string {
+	return e.ErrorString
+}
+
+// Precondition is the state of the metadata in the repository when it was read, so that it is only written back if
+// it has not changed since.
+type Precondition struct {
+	// ETag of the metadata, empty if the repository did not return one
+	ETag string
+	// Exists is false if there was no metadata
+	Exists bool
+}
+
+// Header returns the headers that make a PUT of the metadata conditional on the precondition: If-Match with the ETag,
+// or If-None-Match: * if there was no metadata.
+func (p Precondition) Header() http.Header {
+	h := make(http.Header)
+	if p.ETag != "" {
+		h.Set("If-Match", p.ETag)
+	} else if !p.Exists {
+		h.Set("If-None-Match", "*")
+	}
+	return h
+}
+
+// Writer writes the metadata to the repository conditional on the precondition, returning a Conflict error if the
+// repository rejects the write because the condition failed (412 Precondition Failed).
+type Writer func(ctx context.Context, md MetaData, pc Precondition) error
+
+// Update adds the new version to the artifact level metadata in the repository, writing it with the writer.
+func Update(repoURL, groupID, artifactID, newVersion string, w Writer, cl *http.Client) (MetaData, error) {
+	return UpdateContext(context.Background(), repoURL, groupID, artifactID, newVersion, DefaultUpdateAttempts, repo.FetchOptions{}, w, cl)
+}
+
+// UpdateContext adds the new version to the artifact level metadata as Update does, with the context, making up to
+// attempts writes and verifying the metadata read according to the options.
+// So that a version deployed concurrently is not lost, the write is conditional on the metadata being unchanged since
+// it was read, for repositories that support ETags, and the metadata is read back after it is written to check the
+// version is still listed, for those that do not. If either check fails the current metadata is read again, the
+// version added to it and the write retried. If the metadata read again then does not match its checksums, which
+// another deployment may not yet have written, it is read once more rather than failing the update.
+// Without ETags this narrows but cannot close the window in which another deployment may overwrite the metadata.
+func UpdateContext(ctx context.Context, repoURL, groupID, artifactID, newVersion string, attempts int, opts repo.FetchOptions, w Writer, cl *http.Client) (MetaData, error) {
+	nv, err := version.New(newVersion)
+	if err != nil {
+		return MetaData{}, err
+	}
+	return update(ctx, repoURL, groupID, artifactID, nv.String(), true, attempts, opts, w, cl)
+}
+
+// Remove removes the version from the artifact level metadata in the repository, writing it with the writer.
+func Remove(repoURL, groupID, artifactID, oldVersion string, w Writer, cl *http.Client) (MetaData, error) {
+	return RemoveContext(context.Background(), repoURL, groupID, artifactID, oldVersion, DefaultUpdateAttempts, w, cl)
+}
+
+// RemoveContext removes the version from the artifact level metadata as Remove does, with the context, making up to
+// attempts writes. As with UpdateContext the write is conditional, or the metadata read back, so that versions
+// deployed concurrently are kept, retrying with the current metadata if another deployment changed it. Unlike
+// UpdateContext the metadata is not checked against its checksums.
+// Nothing is written if there is no metadata or the version is not listed.
+func RemoveContext(ctx context.Context, repoURL, groupID, artifactID, oldVersion string, attempts int, w Writer, cl *http.Client) (MetaData, error) {
+	ov, err := version.New(oldVersion)
+	if err != nil {
+		return MetaData{}, err
+	}
+	return update(ctx, repoURL, groupID, artifactID, ov.String(), false, attempts, repo.FetchOptions{}, w, cl)
+}
+
+// update lists the version in the artifact level metadata if add is true, otherwise unlists it, writing the metadata
+// with the writer and retrying if the write conflicts with a concurrent update. The metadata read is verified according
+// to the options when the version is added.
+func update(ctx context.Context, repoURL, groupID, artifactID, v string, add bool, attempts int, opts repo.FetchOptions, w Writer, cl *http.Client) (MetaData, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var md MetaData
+	var err error
+	for attempt := 1; ; attempt++ {
+		var pc Precondition
+		write := true
+		if add {
+			md, pc, err = generate(ctx, repoURL, groupID, artifactID, v, opts, cl)
+		} else {
+			md, pc, write, err = unlist(ctx, repoURL, groupID, artifactID, v, cl)
+		}
+		if err == nil && write {
+			err = w(ctx, md, pc)
+			if err == nil && pc.ETag == "" {
+				err = verify(ctx, URL(repoURL, groupID, artifactID), v, add, cl)
+			}
+		}
+		// a conflict means another deployment has just written the metadata
+		if !conflict(err, attempt > 1) || attempt >= attempts {
+			return md, err
+		}
+		// wait a little so that concurrent deployments are less likely to conflict again
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(conflictBackoff)*int64(attempt)) + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return md, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// unlist gets the current artifact level metadata and removes the version from it, returning the precondition for
+// writing it back. False is returned if there is nothing to write as there is no metadata or the version is not
+// listed.
+func unlist(ctx context.Context, repoURL, groupID, artifactID, v string, cl *http.Client) (MetaData, Precondition, bool, error) {
+	// a version is removed when its deployment failed, which may have left the checksums stale, so they are not checked
+	md, etag, err := fetch(ctx, URL(repoURL, groupID, artifactID), repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyIgnore}, cl)
+	if err != nil {
+		switch err.(type) {
+		case NotFound:
+			return New(groupID, artifactID), Precondition{}, false, nil
+		case repo.ChecksumNotFound, repo.ChecksumMismatch:
+			return md, Precondition{}, false, err
+		default:
+			return md, Precondition{}, false, fmt.Errorf("error getting existing metadata: %v", err)
+		}
+	}
+	if !md.HasVersion(v) {
+		return md, Precondition{}, false, nil
+	}
+	md.RemoveVersion(v)
+	return md, Precondition{ETag: etag, Exists: true}, true, nil
+}
+
+// conflict returns if the error may be due to a concurrent update of the metadata. A checksum mismatch only is if
+// the metadata was read just after another deployment wrote it, as its checksums may not have been written yet. A
+// missing checksum never is, as the metadata may have been deployed without one.
+func conflict(err error, written bool) bool {
+	switch err.(type) {
+	case Conflict:
+		return true
+	case repo.ChecksumMismatch:
+		return written
+	}
+	return false
+}
+
+// verify reads the metadata back, returning a Conflict error if the version is no longer listed, or is listed again
+// if it was removed
+func verify(ctx context.Context, url, v string, listed bool, cl *http.Client) error {
+	// the checksums may be of another deployment's write so they are not checked
+	md, _, err := fetch(ctx, url, repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyIgnore}, cl)
+	if err != nil {
+		return fmt.Errorf("error reading back metadata: %v", err)
+	}
+	if md.HasVersion(v) != listed {
+		action := "overwritten"
+		if !listed {
+			action = "restored"
+		}
+		return Conflict{
+			ErrorString: fmt.Sprintf("version %s %s by a concurrent update of %s", v, action, url),
+		}
+	}
+	return nil
+}
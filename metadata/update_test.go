@@ -0,0 +1,332 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+// metadataServer holds the metadata PUT to it, publishing its sha1, and supports conditional writes with ETags if etags
+// is true
+type metadataServer struct {
+	*httptest.Server
+	mu sync.Mutex
+	md []byte
+	// stale is the number of reads of the sha1 that are answered with one that does not match the metadata
+	stale int
+	// noChecksum, if true, publishes no sha1
+	noChecksum bool
+}
+
+func newMetadataServer(etags bool) *metadataServer {
+	ms := new(metadataServer)
+	ms.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+		if r.URL.Path == "/com/example/example/maven-metadata.xml.sha1" && r.Method == http.MethodGet && ms.md != nil &&
+			!ms.noChecksum {
+			h := sha1.Sum(ms.md)
+			if ms.stale > 0 {
+				ms.stale--
+				h = sha1.Sum(nil)
+			}
+			w.Write([]byte(hex.EncodeToString(h[:])))
+			return
+		}
+		if r.URL.Path != "/com/example/example/maven-metadata.xml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if ms.md == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if etags {
+				w.Header().Set("ETag", ms.etag())
+			}
+			w.Write(ms.md)
+		case http.MethodPut:
+			if etags {
+				if m := r.Header.Get("If-Match"); m != "" && (ms.md == nil || m != ms.etag()) {
+					w.WriteHeader(http.StatusPreconditionFailed)
+					return
+				}
+				if r.Header.Get("If-None-Match") == "*" && ms.md != nil {
+					w.WriteHeader(http.StatusPreconditionFailed)
+					return
+				}
+			}
+			ms.md, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	return ms
+}
+
+func (ms *metadataServer) etag() string {
+	h := sha1.Sum(ms.md)
+	return `"` + hex.EncodeToString(h[:]) + `"`
+}
+
+func (ms *metadataServer) set(md MetaData) {
+	b, _ := md.Marshal()
+	ms.mu.Lock()
+	ms.md = b
+	ms.mu.Unlock()
+}
+
+// writer returns a Writer that PUTs the metadata to the server with the conditional headers, calling before first on
+// each attempt, and the count of its attempts
+func (ms *metadataServer) writer(t *testing.T, before func(attempt int)) (Writer, *int) {
+	attempts := new(int)
+	return func(ctx context.Context, md MetaData, pc Precondition) error {
+		*attempts++
+		before(*attempts)
+		b, err := md.Marshal()
+		if err != nil {
+			return err
+		}
+		req, _ := http.NewRequest("PUT", ms.URL+"/com/example/example/maven-metadata.xml", bytes.NewReader(b))
+		for k, vs := range pc.Header() {
+			req.Header[k] = vs
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error writing metadata: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusPreconditionFailed {
+			return Conflict{ErrorString: "precondition failed"}
+		}
+		if resp.StatusCode != http.StatusCreated {
+			return fmt.Errorf("return code %d", resp.StatusCode)
+		}
+		return nil
+	}, attempts
+}
+
+func TestUpdateContext(t *testing.T) {
+	for _, etags := range []bool{true, false} {
+		ms := newMetadataServer(etags)
+		existing := New("com.example", "example")
+		existing.AddVersion("1.0")
+		ms.set(existing)
+
+		// another deployment adds 1.1 while 1.2 is being deployed
+		concurrent := New("com.example", "example")
+		concurrent.AddVersion("1.0")
+		concurrent.AddVersion("1.1")
+		w, attempts := ms.writer(t, func(attempt int) {
+			if attempt == 1 && etags {
+				// the metadata changes after it was read
+				ms.set(concurrent)
+			}
+		})
+		if !etags {
+			// without ETags the other deployment overwrites the metadata just after it is written
+			inner := w
+			w = func(ctx context.Context, md MetaData, pc Precondition) error {
+				err := inner(ctx, md, pc)
+				if *attempts == 1 {
+					ms.set(concurrent)
+				}
+				return err
+			}
+		}
+		md, err := UpdateContext(context.Background(), ms.URL, "com.example", "example", "1.2", DefaultUpdateAttempts, repo.FetchOptions{}, w, nil)
+		if err != nil {
+			t.Fatalf("etags %t: error updating metadata: %v", etags, err)
+		}
+		assert.Equal(t, 2, *attempts, "etags %t: conflicting write should have been retried", etags)
+		assert.Equal(t, []string{"1.0", "1.1", "1.2"}, md.Versioning.Versions.String(), "etags %t", etags)
+		stored, err := Get(ms.URL, "com.example", "example", nil)
+		if err != nil {
+			t.Fatalf("etags %t: error getting metadata: %v", etags, err)
+		}
+		assert.Equal(t, []string{"1.0", "1.1", "1.2"}, stored.Versioning.Versions.String(), "etags %t: version lost", etags)
+		ms.Close()
+	}
+}
+
+func TestUpdateContext_New(t *testing.T) {
+	ms := newMetadataServer(true)
+	defer ms.Close()
+	// another deployment creates the metadata first
+	concurrent := New("com.example", "example")
+	concurrent.AddVersion("1.0")
+	w, attempts := ms.writer(t, func(attempt int) {
+		if attempt == 1 {
+			ms.set(concurrent)
+		}
+	})
+	md, err := UpdateContext(context.Background(), ms.URL, "com.example", "example", "2.0", DefaultUpdateAttempts, repo.FetchOptions{}, w, nil)
+	if err != nil {
+		t.Fatalf("error updating metadata: %v", err)
+	}
+	assert.Equal(t, 2, *attempts)
+	assert.Equal(t, []string{"1.0", "2.0"}, md.Versioning.Versions.String())
+}
+
+func TestUpdateContext_Exhausted(t *testing.T) {
+	ms := newMetadataServer(true)
+	defer ms.Close()
+	// the metadata changes before every write
+	w, attempts := ms.writer(t, func(attempt int) {
+		md := New("com.example", "example")
+		md.AddVersion(fmt.Sprintf("1.%d", attempt))
+		ms.set(md)
+	})
+	_, err := UpdateContext(context.Background(), ms.URL, "com.example", "example", "2.0", 3, repo.FetchOptions{}, w, nil)
+	if _, ok := err.(Conflict); !ok {
+		t.Errorf("expected Conflict error, got: %v", err)
+	}
+	assert.Equal(t, 3, *attempts)
+}
+
+func TestUpdateContext_Checksums(t *testing.T) {
+	ms := newMetadataServer(true)
+	defer ms.Close()
+	md := New("com.example", "example")
+	md.AddVersion("1.0")
+	ms.set(md)
+	ms.noChecksum = true
+	w, attempts := ms.writer(t, func(attempt int) {})
+
+	// metadata without a checksum is not mistaken for a concurrent update
+	_, err := UpdateContext(context.Background(), ms.URL, "com.example", "example", "2.0", DefaultUpdateAttempts, repo.FetchOptions{}, w, nil)
+	if _, ok := err.(repo.ChecksumNotFound); !ok {
+		t.Errorf("expected ChecksumNotFound error, got: %v", err)
+	}
+	assert.Equal(t, 0, *attempts, "metadata without a checksum should not be written")
+	warn := repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyWarn, Logger: log.New(ioutil.Discard, "", 0)}
+	md, err = UpdateContext(context.Background(), ms.URL, "com.example", "example", "2.0", DefaultUpdateAttempts, warn, w, nil)
+	if err != nil {
+		t.Fatalf("error updating metadata without a checksum under the warn policy: %v", err)
+	}
+	assert.Equal(t, []string{"1.0", "2.0"}, md.Versioning.Versions.String())
+
+	// a mismatch on the first read is not a concurrent update
+	ms.noChecksum = false
+	ms.stale = DefaultUpdateAttempts
+	*attempts = 0
+	_, err = UpdateContext(context.Background(), ms.URL, "com.example", "example", "3.0", DefaultUpdateAttempts, repo.FetchOptions{}, w, nil)
+	if _, ok := err.(repo.ChecksumMismatch); !ok {
+		t.Errorf("expected ChecksumMismatch error, got: %v", err)
+	}
+	assert.Equal(t, 0, *attempts, "metadata not matching its checksum should not be written")
+
+	// but is after another deployment wrote the metadata, whose checksum is then written
+	ms.stale = 0
+	w, attempts = ms.writer(t, func(attempt int) {
+		if attempt == 1 {
+			concurrent := New("com.example", "example")
+			concurrent.AddVersion("1.5")
+			ms.set(concurrent)
+			ms.mu.Lock()
+			ms.stale = 1
+			ms.mu.Unlock()
+		}
+	})
+	md, err = UpdateContext(context.Background(), ms.URL, "com.example", "example", "3.0", DefaultUpdateAttempts, repo.FetchOptions{}, w, nil)
+	if err != nil {
+		t.Fatalf("error updating metadata: %v", err)
+	}
+	assert.Equal(t, 2, *attempts)
+	assert.Equal(t, []string{"1.5", "3.0"}, md.Versioning.Versions.String())
+}
+
+func TestRemoveContext(t *testing.T) {
+	for _, etags := range []bool{true, false} {
+		ms := newMetadataServer(etags)
+		existing := New("com.example", "example")
+		existing.AddVersion("1.0")
+		existing.AddVersion("1.1")
+		ms.set(existing)
+
+		// another deployment adds 1.2 while 1.1 is being removed
+		concurrent := New("com.example", "example")
+		concurrent.AddVersion("1.0")
+		concurrent.AddVersion("1.1")
+		concurrent.AddVersion("1.2")
+		w, attempts := ms.writer(t, func(attempt int) {
+			if attempt == 1 && etags {
+				ms.set(concurrent)
+			}
+		})
+		if !etags {
+			inner := w
+			w = func(ctx context.Context, md MetaData, pc Precondition) error {
+				err := inner(ctx, md, pc)
+				if *attempts == 1 {
+					ms.set(concurrent)
+				}
+				return err
+			}
+		}
+		md, err := RemoveContext(context.Background(), ms.URL, "com.example", "example", "1.1", DefaultUpdateAttempts, w, nil)
+		if err != nil {
+			t.Fatalf("etags %t: error removing version: %v", etags, err)
+		}
+		assert.Equal(t, 2, *attempts, "etags %t: conflicting write should have been retried", etags)
+		assert.Equal(t, []string{"1.0", "1.2"}, md.Versioning.Versions.String(), "etags %t", etags)
+		stored, err := Get(ms.URL, "com.example", "example", nil)
+		if err != nil {
+			t.Fatalf("etags %t: error getting metadata: %v", etags, err)
+		}
+		assert.Equal(t, []string{"1.0", "1.2"}, stored.Versioning.Versions.String(), "etags %t: concurrent version lost", etags)
+		assert.Equal(t, "1.2", stored.Versioning.Latest.String(), "etags %t", etags)
+		ms.Close()
+	}
+}
+
+func TestRemoveContext_NotListed(t *testing.T) {
+	ms := newMetadataServer(true)
+	defer ms.Close()
+	w, attempts := ms.writer(t, func(int) {})
+	_, err := RemoveContext(context.Background(), ms.URL, "com.example", "example", "1.0", DefaultUpdateAttempts, w, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, *attempts, "missing metadata should not be written")
+
+	existing := New("com.example", "example")
+	existing.AddVersion("1.0")
+	ms.set(existing)
+	_, err = RemoveContext(context.Background(), ms.URL, "com.example", "example", "2.0", DefaultUpdateAttempts, w, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, *attempts, "metadata without the version should not be written")
+}
+
+func TestMetaData_RemoveVersion(t *testing.T) {
+	md := New("com.example", "example")
+	md.AddVersion("1.0")
+	md.AddVersion("1.1")
+	md.AddVersion("1.2-SNAPSHOT")
+	md.RemoveVersion("1.1")
+	assert.Equal(t, []string{"1.0", "1.2-SNAPSHOT"}, md.Versioning.Versions.String())
+	assert.Equal(t, "1.2-SNAPSHOT", md.Versioning.Latest.String())
+	assert.Equal(t, "1.0", md.Versioning.Release.String())
+	md.RemoveVersion("1.2-SNAPSHOT")
+	md.RemoveVersion("1.0")
+	assert.Equal(t, 0, len(*md.Versioning.Versions))
+	assert.Nil(t, md.Versioning.Latest)
+	assert.Nil(t, md.Versioning.Release)
+	assert.Nil(t, md.Version)
+}
+
+func TestPrecondition_Header(t *testing.T) {
+	assert.Equal(t, `"abc"`, Precondition{ETag: `"abc"`, Exists: true}.Header().Get("If-Match"))
+	assert.Equal(t, "*", Precondition{}.Header().Get("If-None-Match"))
+	assert.Equal(t, 0, len(Precondition{Exists: true}.Header()))
+}
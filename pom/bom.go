@@ -0,0 +1,125 @@
+package pom
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	scopeImport = "import"
+	typePOM     = "pom"
+)
+
+// IsImport reports if the dependency imports the dependencyManagement of a BOM
+func (d Dependency) IsImport() bool {
+	return d.Scope == scopeImport
+}
+
+// ManagedVersions returns the versions managed by the dependencyManagement of the POM, by the
+// groupId:artifactId:type:classifier Key of the dependency. Where a dependency is managed more than once the first
+// declaration wins. The versions managed by the BOMs it imports are only included once they have been imported, as
+// in an effective POM.
+func (p *POM) ManagedVersions() map[string]string {
+	m := make(map[string]string)
+	if p.DependencyManagement == nil || p.DependencyManagement.Dependencies == nil {
+		return m
+	}
+	for _, d := range *p.DependencyManagement.Dependencies {
+		if d.IsImport() || d.Version == "" {
+			continue
+		}
+		if _, ok := m[d.Key()]; !ok {
+			m[d.Key()] = d.Version
+		}
+	}
+	return m
+}
+
+// importedBOM is the managed dependencies of the effective POM of a BOM, along with any InterpolationError of it
+type importedBOM struct {
+	deps []Dependency
+	ierr error
+}
+
+// importBOMs replaces the import scoped dependencies of the dependencyManagement of the interpolated POM with the
+// managed dependencies of the effective POMs of the BOMs they refer to. As with maven the dependencies the POM manages
+// itself take precedence, followed by those of each BOM in the order they are declared.
+// The expressions of the BOMs that could not be interpolated are returned as an InterpolationError.
+func (b *builder) importBOMs(ctx context.Context, p POM) (POM, error) {
+	if p.DependencyManagement == nil || p.DependencyManagement.Dependencies == nil {
+		return p, nil
+	}
+	var managed, imports []Dependency
+	for _, d := range *p.DependencyManagement.Dependencies {
+		if d.IsImport() {
+			imports = append(imports, d)
+			continue
+		}
+		managed = append(managed, d)
+	}
+	if len(imports) == 0 {
+		return p, nil
+	}
+	b.importing = append(b.importing, coordinates(p))
+	defer func() { b.importing = b.importing[:len(b.importing)-1] }()
+	keys := make(map[string]bool)
+	for _, d := range managed {
+		keys[d.Key()] = true
+	}
+	var ierr error
+	for _, imp := range imports {
+		deps, err := b.bom(ctx, imp)
+		if _, ok := err.(InterpolationError); err != nil && !ok {
+			return p, fmt.Errorf("error importing dependencyManagement of %s into %s: %v", imp.Key(), coordinates(p), err)
+		}
+		ierr = joinInterpolationErrors(ierr, err)
+		for _, d := range deps {
+			if !keys[d.Key()] {
+				keys[d.Key()] = true
+				managed = append(managed, d)
+			}
+		}
+	}
+	dm := *p.DependencyManagement
+	dm.Dependencies = &managed
+	p.DependencyManagement = &dm
+	return p, ierr
+}
+
+// bom returns the managed dependencies of the effective POM of the BOM the import scoped dependency refers to, along
+// with any InterpolationError of the effective POM
+func (b *builder) bom(ctx context.Context, imp Dependency) ([]Dependency, error) {
+	if imp.Type != typePOM {
+		return nil, fmt.Errorf("dependency with scope import must have type pom, not %q", imp.Type)
+	}
+	if imp.Version == "" {
+		return nil, fmt.Errorf("dependency with scope import has no version")
+	}
+	gav := fmt.Sprintf("%s:%s:%s", imp.GroupID, imp.ArtifactID, imp.Version)
+	if imported, ok := b.boms[gav]; ok {
+		return imported.deps, imported.ierr
+	}
+	for i, c := range b.importing {
+		if c == gav {
+			return nil, fmt.Errorf("cycle importing BOMs: %s -> %s", strings.Join(b.importing[i:], " -> "), gav)
+		}
+	}
+	if b.repoURL == "" {
+		return nil, fmt.Errorf("no repository to fetch %s from", gav)
+	}
+	p, err := GetContext(ctx, b.repoURL, imp.GroupID, imp.ArtifactID, imp.Version, b.opts, b.cl)
+	if err != nil {
+		return nil, err
+	}
+	eff, ierr := b.effective(ctx, p, "")
+	if _, ok := ierr.(InterpolationError); ierr != nil && !ok {
+		return nil, ierr
+	}
+	var deps []Dependency
+	if eff.DependencyManagement != nil && eff.DependencyManagement.Dependencies != nil {
+		deps = *eff.DependencyManagement.Dependencies
+	}
+	b.boms[gav] = importedBOM{deps: deps, ierr: ierr}
+	return deps, ierr
+}
@@ -0,0 +1,129 @@
+package pom
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bomPOM returns a POM of com.example:artifactID:1 managing the dependencies, given as groupId:artifactId:version or
+// groupId:artifactId:version:import
+func bomPOM(artifactID string, deps ...string) string {
+	var dm []string
+	for _, d := range deps {
+		c := strings.Split(d, ":")
+		x := fmt.Sprintf("<dependency><groupId>%s</groupId><artifactId>%s</artifactId><version>%s</version>", c[0], c[1], c[2])
+		if len(c) > 3 {
+			x += "<type>pom</type><scope>import</scope>"
+		}
+		dm = append(dm, x+"</dependency>")
+	}
+	return fmt.Sprintf(`<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>com.example</groupId>
+  <artifactId>%s</artifactId>
+  <version>1</version>
+  <packaging>pom</packaging>
+  <properties>
+    <bom.version>1</bom.version>
+  </properties>
+  <dependencyManagement>
+    <dependencies>%s</dependencies>
+  </dependencyManagement>
+</project>`, artifactID, strings.Join(dm, ""))
+}
+
+func TestEffective_ImportBOMs(t *testing.T) {
+	poms := make(map[string]string)
+	for a, deps := range map[string][]string{
+		"app": {"com.fasterxml.jackson.core:jackson-databind:2.0", "com.example:bom-a:${bom.version}:import",
+			"com.example:bom-b:1:import"},
+		"bom-a": {"com.fasterxml.jackson.core:jackson-databind:1.0", "com.fasterxml.jackson.core:jackson-core:1.0",
+			"com.example:bom-c:1:import"},
+		"bom-b":    {"com.google.guava:guava:31.1-jre", "junit:junit:4.13.2"},
+		"bom-c":    {"com.google.guava:guava:30.0-jre", "com.fasterxml.jackson.core:jackson-core:0.9"},
+		"cyclic":   {"com.example:cyclic-a:1:import"},
+		"cyclic-a": {"com.example:cyclic-b:1:import"},
+		"cyclic-b": {"com.example:cyclic-a:1:import"},
+	} {
+		poms[fmt.Sprintf("com/example/%s/1/%s-1.pom", a, a)] = bomPOM(a, deps...)
+	}
+	poms["com/example/jar/1/jar-1.pom"] = strings.Replace(bomPOM("jar", "com.example:bom-b:1:import"), "<type>pom</type>", "", 1)
+	s := pomRepo(poms)
+	defer s.Close()
+
+	p, err := Effective(s.URL, "com.example", "app", "1", nil, nil)
+	if err != nil {
+		t.Fatalf("error getting effective POM: %v", err)
+	}
+	var managed []string
+	for _, d := range *p.DependencyManagement.Dependencies {
+		managed = append(managed, d.GroupID+":"+d.ArtifactID+":"+d.Version)
+	}
+	assert.Equal(t, []string{
+		"com.fasterxml.jackson.core:jackson-databind:2.0",
+		"com.fasterxml.jackson.core:jackson-core:1.0",
+		"com.google.guava:guava:30.0-jre",
+		"junit:junit:4.13.2",
+	}, managed, "managed dependencies should be imported in declaration order with the first declaration winning")
+	assert.Equal(t, map[string]string{
+		"com.fasterxml.jackson.core:jackson-databind:jar:": "2.0",
+		"com.fasterxml.jackson.core:jackson-core:jar:":     "1.0",
+		"com.google.guava:guava:jar:":                      "30.0-jre",
+		"junit:junit:jar:":                                 "4.13.2",
+	}, p.ManagedVersions())
+
+	_, err = Effective(s.URL, "com.example", "cyclic", "1", nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cycle importing BOMs: com.example:cyclic-a:1 -> com.example:cyclic-b:1 -> com.example:cyclic-a:1")
+	}
+	_, err = Effective(s.URL, "com.example", "jar", "1", nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "must have type pom")
+	}
+}
+
+func TestEffective_ImportBOMs_InterpolationError(t *testing.T) {
+	poms := map[string]string{
+		"com/example/app/1/app-1.pom":       bomPOM("app", "com.example:a:${app.undefined}", "com.example:bom:1:import"),
+		"com/example/bom/1/bom-1.pom":       bomPOM("bom", "com.example:b:${bom.undefined}", "com.example:c:${bom.version}"),
+		"com/example/broken/1/broken-1.pom": bomPOM("broken", "com.example:a:${broken.undefined}", "com.example:missing:1:import"),
+	}
+	s := pomRepo(poms)
+	defer s.Close()
+
+	p, err := Effective(s.URL, "com.example", "app", "1", nil, nil)
+	e, ok := err.(InterpolationError)
+	if !ok {
+		t.Fatalf("expected InterpolationError, got: %v", err)
+	}
+	assert.Equal(t, []string{"app.undefined", "bom.undefined"}, e.Unresolved, "expressions of the imported BOM should be reported")
+	assert.Equal(t, map[string]string{
+		"com.example:a:jar:": "${app.undefined}",
+		"com.example:b:jar:": "${bom.undefined}",
+		"com.example:c:jar:": "1",
+	}, p.ManagedVersions())
+
+	_, err = Effective(s.URL, "com.example", "broken", "1", nil, nil)
+	if _, ok := err.(InterpolationError); ok || err == nil {
+		t.Fatalf("expected import error, got: %v", err)
+	}
+	assert.Contains(t, err.Error(), "error importing dependencyManagement of com.example:missing")
+	assert.Contains(t, err.Error(), "${broken.undefined}", "the interpolation error should not be lost")
+}
+
+func TestPOM_ManagedVersions(t *testing.T) {
+	p := New("com.example", "example", "1", "jar")
+	p.DependencyManagement = &DependencyManagement{Dependencies: &[]Dependency{
+		{GroupID: "com.example", ArtifactID: "a", Version: "1"},
+		{GroupID: "com.example", ArtifactID: "a", Classifier: "tests", Type: "test-jar", Version: "2"},
+		{GroupID: "com.example", ArtifactID: "a", Version: "3"},
+		{GroupID: "com.example", ArtifactID: "bom", Version: "1", Type: "pom", Scope: "import"},
+	}}
+	assert.Equal(t, map[string]string{
+		"com.example:a:jar:":           "1",
+		"com.example:a:test-jar:tests": "2",
+	}, p.ManagedVersions())
+}
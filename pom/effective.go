@@ -0,0 +1,520 @@
+package pom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jcmturner/gomvn/repo"
+)
+
+const (
+	defaultRelativePath = "../pom.xml"
+	defaultPluginGroup  = "org.apache.maven.plugins"
+	centralID           = "central"
+	centralURL          = "https://repo.maven.apache.org/maven2"
+	inheritFalse        = "false"
+)
+
+// superPOM is the part of maven's super POM that every project inherits: the central repository
+var superPOM = POM{
+	Repositories: &[]Repository{{
+		ID:        centralID,
+		Name:      "Central Repository",
+		URL:       centralURL,
+		Layout:    "default",
+		Snapshots: &RepoPolicy{Enabled: "false"},
+	}},
+	PluginRepositories: &[]Repository{{
+		ID:        centralID,
+		Name:      "Central Repository",
+		URL:       centralURL,
+		Layout:    "default",
+		Snapshots: &RepoPolicy{Enabled: "false"},
+		Releases:  &RepoPolicy{UpdatePolicy: "never"},
+	}},
+}
+
+// Effective returns the effective POM of the artifact version in the repository, as shown by
+// mvn help:effective-pom. See EffectiveContext.
+func Effective(repoURL, groupID, artifactID, version string, props map[string]string, cl *http.Client) (POM, error) {
+	return EffectiveContext(context.Background(), repoURL, groupID, artifactID, version, props, repo.FetchOptions{}, cl)
+}
+
+// EffectiveContext returns the effective POM of the artifact version in the repository with the context, verifying
+// the POMs fetched according to the options.
+// The POM inherits from its chain of parents, fetched from the repository, following maven's inheritance rules, and
+// from the central repository of maven's super POM. It is then interpolated with the properties given as
+// Interpolate does, the BOMs its dependencyManagement imports are imported, and it is returned along with any
+// InterpolationError, which includes the expressions of the imported BOMs that could not be interpolated. If a BOM
+// cannot be imported the error returned also describes any expressions that could not be interpolated.
+// Profiles are not activated, plugin configuration is not merged with that of the parent, and the build defaults of
+// the super POM are not added.
+func EffectiveContext(ctx context.Context, repoURL, groupID, artifactID, version string, props map[string]string, opts repo.FetchOptions, cl *http.Client) (POM, error) {
+	p, err := GetContext(ctx, repoURL, groupID, artifactID, version, opts, cl)
+	if err != nil {
+		return p, err
+	}
+	return newBuilder(repoURL, props, opts, cl).effective(ctx, p, "")
+}
+
+// LoadEffective returns the effective POM of the project file at path. See LoadEffectiveContext.
+func LoadEffective(path, repoURL string, props map[string]string, cl *http.Client) (POM, error) {
+	return LoadEffectiveContext(context.Background(), path, repoURL, props, repo.FetchOptions{}, cl)
+}
+
+// LoadEffectiveContext returns the effective POM of the project file at path as EffectiveContext does. As with maven
+// a parent is loaded from its relativePath, ../pom.xml if not set, if the POM there has the parent's coordinates,
+// otherwise it is fetched from the repository. The project.basedir and basedir properties are the directory of the
+// file unless given.
+func LoadEffectiveContext(ctx context.Context, path, repoURL string, props map[string]string, opts repo.FetchOptions, cl *http.Client) (POM, error) {
+	p, err := Load(path)
+	if err != nil {
+		return p, err
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return p, fmt.Errorf("could not determine directory of %s: %v", path, err)
+	}
+	return newBuilder(repoURL, props, opts, cl).effective(ctx, p, dir)
+}
+
+// builder builds effective POMs from a repository
+type builder struct {
+	repoURL string
+	props   map[string]string
+	opts    repo.FetchOptions
+	cl      *http.Client
+	// boms are the BOMs imported, by coordinates
+	boms map[string]importedBOM
+	// importing are the POMs importing BOMs, in order, to detect cycles
+	importing []string
+}
+
+func newBuilder(repoURL string, props map[string]string, opts repo.FetchOptions, cl *http.Client) *builder {
+	return &builder{
+		repoURL: repoURL,
+		props:   props,
+		opts:    opts,
+		cl:      cl,
+		boms:    make(map[string]importedBOM),
+	}
+}
+
+// effective builds the effective POM of p. If dir is not empty it is the directory of p on disk, used as its basedir
+// and to find its parent by its relative path.
+func (b *builder) effective(ctx context.Context, p POM, dir string) (POM, error) {
+	props := b.props
+	if dir != "" {
+		props = map[string]string{"project.basedir": dir, "basedir": dir}
+		for k, v := range b.props {
+			props[k] = v
+		}
+	}
+	// the chain of POMs from p to its top most parent
+	chain := []POM{p}
+	seen := map[string]bool{coordinates(p): true}
+	for p.Parent != nil {
+		var err error
+		p, dir, err = b.parent(ctx, *p.Parent, dir)
+		if err != nil {
+			return chain[0], err
+		}
+		if seen[coordinates(p)] {
+			return chain[0], fmt.Errorf("cycle in the parents of %s at %s", coordinates(chain[0]), coordinates(p))
+		}
+		seen[coordinates(p)] = true
+		chain = append(chain, p)
+	}
+	eff := superPOM
+	for i := len(chain) - 1; i >= 0; i-- {
+		eff = inherit(chain[i], eff)
+	}
+	eff, ierr := eff.Interpolate(props)
+	eff, err := b.importBOMs(ctx, eff)
+	if _, ok := err.(InterpolationError); err != nil && !ok {
+		if ierr != nil {
+			err = fmt.Errorf("%v; %v", err, ierr)
+		}
+		return eff, err
+	}
+	return eff, joinInterpolationErrors(ierr, err)
+}
+
+// coordinates returns groupId:artifactId:version of the POM, those of its parent if not set
+func coordinates(p POM) string {
+	g, v := p.GroupID, p.Version
+	if p.Parent != nil {
+		if g == "" {
+			g = p.Parent.GroupID
+		}
+		if v == "" {
+			v = p.Parent.Version
+		}
+	}
+	return fmt.Sprintf("%s:%s:%s", g, p.ArtifactID, v)
+}
+
+// parent returns the parent POM and the directory it was loaded from, empty if it was fetched from the repository.
+// If dir is not empty the parent is first looked for at its relative path from it.
+func (b *builder) parent(ctx context.Context, pr Parent, dir string) (POM, string, error) {
+	want := fmt.Sprintf("%s:%s:%s", pr.GroupID, pr.ArtifactID, pr.Version)
+	if dir != "" {
+		rel := defaultRelativePath
+		if pr.RelativePath != nil {
+			rel = *pr.RelativePath
+		}
+		if rel != "" {
+			path := filepath.Join(dir, filepath.FromSlash(rel))
+			if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+				path = filepath.Join(path, pomFile)
+			}
+			if _, err := os.Stat(path); err == nil {
+				p, err := Load(path)
+				if err != nil {
+					return p, "", err
+				}
+				// a POM at the relative path that is not the parent is ignored, as with maven
+				if coordinates(p) == want {
+					return p, filepath.Dir(path), nil
+				}
+			}
+		}
+	}
+	if b.repoURL == "" {
+		return POM{}, "", fmt.Errorf("parent %s not found and no repository to fetch it from", want)
+	}
+	p, err := GetContext(ctx, b.repoURL, pr.GroupID, pr.ArtifactID, pr.Version, b.opts, b.cl)
+	if err != nil {
+		return p, "", fmt.Errorf("error getting parent %s: %v", want, err)
+	}
+	return p, "", nil
+}
+
+// inherit returns the child merged with its parent, which has already been merged with its own parents, following
+// maven's inheritance rules. The artifactId, name, packaging, modules, prerequisites and profiles are not inherited.
+func inherit(c, p POM) POM {
+	if c.GroupID == "" {
+		c.GroupID = p.GroupID
+	}
+	if c.Version == "" {
+		c.Version = p.Version
+	}
+	if c.Description == "" {
+		c.Description = p.Description
+	}
+	if c.ChildProjectURLInheritAppendPath == "" {
+		c.ChildProjectURLInheritAppendPath = p.ChildProjectURLInheritAppendPath
+	}
+	if c.URL == "" {
+		c.URL = childPath(p.URL, c.ArtifactID, p.ChildProjectURLInheritAppendPath)
+	}
+	if c.InceptionYear == "" {
+		c.InceptionYear = p.InceptionYear
+	}
+	if c.Organization == nil {
+		c.Organization = p.Organization
+	}
+	// lists of people and licenses are inherited as a whole if not set
+	if c.Licenses == nil {
+		c.Licenses = p.Licenses
+	}
+	if c.Developers == nil {
+		c.Developers = p.Developers
+	}
+	if c.Contributors == nil {
+		c.Contributors = p.Contributors
+	}
+	if c.MailingLists == nil {
+		c.MailingLists = p.MailingLists
+	}
+	c.SCM = inheritSCM(c.SCM, p.SCM, c.ArtifactID)
+	if c.IssueManagement == nil {
+		c.IssueManagement = p.IssueManagement
+	}
+	if c.CIManagement == nil {
+		c.CIManagement = p.CIManagement
+	}
+	c.DistributionManagement = inheritDistributionManagement(c.DistributionManagement, p.DistributionManagement, c.ArtifactID)
+	c.Properties = mergeProperties(c.Properties, p.Properties)
+	if p.DependencyManagement != nil {
+		dm := DependencyManagement{}
+		if c.DependencyManagement != nil {
+			dm = *c.DependencyManagement
+		}
+		dm.Dependencies = mergeDependencies(dm.Dependencies, p.DependencyManagement.Dependencies)
+		c.DependencyManagement = &dm
+	}
+	c.Dependencies = mergeDependencies(c.Dependencies, p.Dependencies)
+	c.Repositories = mergeRepositories(c.Repositories, p.Repositories)
+	c.PluginRepositories = mergeRepositories(c.PluginRepositories, p.PluginRepositories)
+	c.Build = inheritBuild(c.Build, p.Build)
+	if c.Reporting == nil {
+		c.Reporting = p.Reporting
+	}
+	return c
+}
+
+// childPath returns the URL of the parent with the artifactId of the child appended, unless the parent sets
+// appendPath to false.
+func childPath(parentURL, artifactID, appendPath string) string {
+	if parentURL == "" || appendPath == inheritFalse {
+		return parentURL
+	}
+	if strings.HasSuffix(parentURL, "/") {
+		return parentURL + artifactID
+	}
+	return parentURL + "/" + artifactID
+}
+
+func inheritSCM(c, p *SCM, artifactID string) *SCM {
+	if p == nil {
+		return c
+	}
+	var s SCM
+	if c != nil {
+		s = *c
+	}
+	if s.ChildSCMConnectionInheritAppendPath == "" {
+		s.ChildSCMConnectionInheritAppendPath = p.ChildSCMConnectionInheritAppendPath
+	}
+	if s.ChildSCMDeveloperConnectionInheritAppendPath == "" {
+		s.ChildSCMDeveloperConnectionInheritAppendPath = p.ChildSCMDeveloperConnectionInheritAppendPath
+	}
+	if s.ChildSCMURLInheritAppendPath == "" {
+		s.ChildSCMURLInheritAppendPath = p.ChildSCMURLInheritAppendPath
+	}
+	if s.Connection == "" {
+		s.Connection = childPath(p.Connection, artifactID, p.ChildSCMConnectionInheritAppendPath)
+	}
+	if s.DeveloperConnection == "" {
+		s.DeveloperConnection = childPath(p.DeveloperConnection, artifactID, p.ChildSCMDeveloperConnectionInheritAppendPath)
+	}
+	if s.Tag == "" {
+		s.Tag = p.Tag
+	}
+	if s.URL == "" {
+		s.URL = childPath(p.URL, artifactID, p.ChildSCMURLInheritAppendPath)
+	}
+	return &s
+}
+
+// inheritDistributionManagement inherits the repositories and site, but not the relocation or status
+func inheritDistributionManagement(c, p *DistributionManagement, artifactID string) *DistributionManagement {
+	if p == nil {
+		return c
+	}
+	var d DistributionManagement
+	if c != nil {
+		d = *c
+	}
+	if d.Repository == nil {
+		d.Repository = p.Repository
+	}
+	if d.SnapshotRepository == nil {
+		d.SnapshotRepository = p.SnapshotRepository
+	}
+	if d.Site == nil && p.Site != nil {
+		s := *p.Site
+		s.URL = childPath(s.URL, artifactID, s.ChildSiteURLInheritAppendPath)
+		d.Site = &s
+	}
+	if d.DownloadURL == "" {
+		d.DownloadURL = p.DownloadURL
+	}
+	if d == (DistributionManagement{}) {
+		return nil
+	}
+	return &d
+}
+
+// mergeProperties returns the properties of the parent overridden and added to by those of the child
+func mergeProperties(c, p Properties) Properties {
+	if len(p) == 0 {
+		return c
+	}
+	m := append(Properties{}, p...)
+	for _, prop := range c {
+		m.Set(prop.Name, prop.Value)
+	}
+	return m
+}
+
+// Key returns the groupId:artifactId:type:classifier that identifies the dependency within a list of dependencies
+func (d Dependency) Key() string {
+	t := d.Type
+	if t == "" {
+		t = repo.DefaultExtension
+	}
+	return fmt.Sprintf("%s:%s:%s:%s", d.GroupID, d.ArtifactID, t, d.Classifier)
+}
+
+// mergeDependencies returns the dependencies of the child followed by those of the parent it does not also declare
+func mergeDependencies(c, p *[]Dependency) *[]Dependency {
+	if p == nil || len(*p) == 0 {
+		return c
+	}
+	var m []Dependency
+	keys := make(map[string]bool)
+	if c != nil {
+		for _, d := range *c {
+			keys[d.Key()] = true
+			m = append(m, d)
+		}
+	}
+	for _, d := range *p {
+		if !keys[d.Key()] {
+			m = append(m, d)
+		}
+	}
+	return &m
+}
+
+// mergeRepositories returns the repositories of the child followed by those of the parent with other ids
+func mergeRepositories(c, p *[]Repository) *[]Repository {
+	if p == nil || len(*p) == 0 {
+		return c
+	}
+	var m []Repository
+	ids := make(map[string]bool)
+	if c != nil {
+		for _, r := range *c {
+			ids[r.ID] = true
+			m = append(m, r)
+		}
+	}
+	for _, r := range *p {
+		if !ids[r.ID] {
+			m = append(m, r)
+		}
+	}
+	return &m
+}
+
+func inheritBuild(c, p *Build) *Build {
+	if p == nil {
+		return c
+	}
+	var b Build
+	if c != nil {
+		b = *c
+	}
+	for _, f := range []struct {
+		c *string
+		p string
+	}{
+		{&b.SourceDirectory, p.SourceDirectory},
+		{&b.ScriptSourceDirectory, p.ScriptSourceDirectory},
+		{&b.TestSourceDirectory, p.TestSourceDirectory},
+		{&b.OutputDirectory, p.OutputDirectory},
+		{&b.TestOutputDirectory, p.TestOutputDirectory},
+		{&b.DefaultGoal, p.DefaultGoal},
+		{&b.Directory, p.Directory},
+		{&b.FinalName, p.FinalName},
+	} {
+		if *f.c == "" {
+			*f.c = f.p
+		}
+	}
+	if b.Extensions == nil {
+		b.Extensions = p.Extensions
+	}
+	if b.Resources == nil {
+		b.Resources = p.Resources
+	}
+	if b.TestResources == nil {
+		b.TestResources = p.TestResources
+	}
+	if b.Filters == nil {
+		b.Filters = p.Filters
+	}
+	if p.PluginManagement != nil {
+		pm := PluginManagement{}
+		if b.PluginManagement != nil {
+			pm = *b.PluginManagement
+		}
+		pm.Plugins = mergePlugins(pm.Plugins, p.PluginManagement.Plugins)
+		b.PluginManagement = &pm
+	}
+	b.Plugins = mergePlugins(b.Plugins, p.Plugins)
+	return &b
+}
+
+// Key returns the groupId:artifactId that identifies the plugin, the groupId defaulting to org.apache.maven.plugins
+func (p Plugin) Key() string {
+	g := p.GroupID
+	if g == "" {
+		g = defaultPluginGroup
+	}
+	return g + ":" + p.ArtifactID
+}
+
+// mergePlugins returns the plugins of the child followed by those of the parent not declared by the child and not
+// marked as not inherited. The version, executions and dependencies of a plugin declared by both are inherited, but
+// configuration is only inherited if the child does not configure the plugin.
+func mergePlugins(c, p *[]Plugin) *[]Plugin {
+	if p == nil || len(*p) == 0 {
+		return c
+	}
+	parents := make(map[string]Plugin)
+	for _, pl := range *p {
+		if pl.Inherited != inheritFalse {
+			parents[pl.Key()] = pl
+		}
+	}
+	var m []Plugin
+	declared := make(map[string]bool)
+	if c != nil {
+		for _, pl := range *c {
+			declared[pl.Key()] = true
+			if pp, ok := parents[pl.Key()]; ok {
+				pl = inheritPlugin(pl, pp)
+			}
+			m = append(m, pl)
+		}
+	}
+	for _, pl := range *p {
+		if pl.Inherited != inheritFalse && !declared[pl.Key()] {
+			m = append(m, pl)
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return &m
+}
+
+func inheritPlugin(c, p Plugin) Plugin {
+	if c.Version == "" {
+		c.Version = p.Version
+	}
+	if c.Extensions == "" {
+		c.Extensions = p.Extensions
+	}
+	if c.Configuration == nil {
+		c.Configuration = p.Configuration
+	}
+	c.Dependencies = mergeDependencies(c.Dependencies, p.Dependencies)
+	if p.Executions != nil {
+		var es []PluginExecution
+		ids := make(map[string]bool)
+		if c.Executions != nil {
+			for _, e := range *c.Executions {
+				ids[e.ID] = true
+				es = append(es, e)
+			}
+		}
+		for _, e := range *p.Executions {
+			if e.Inherited != inheritFalse && !ids[e.ID] {
+				es = append(es, e)
+			}
+		}
+		if len(es) > 0 {
+			c.Executions = &es
+		}
+	}
+	return c
+}
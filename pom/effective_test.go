@@ -0,0 +1,260 @@
+package pom
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const grandparentPOM = `<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>com.example</groupId>
+  <artifactId>grandparent</artifactId>
+  <version>1</version>
+  <packaging>pom</packaging>
+  <name>Grandparent</name>
+  <description>Shared build</description>
+  <url>https://example.com/</url>
+  <licenses>
+    <license>
+      <name>Apache-2.0</name>
+    </license>
+  </licenses>
+  <scm>
+    <connection>scm:git:https://example.com/repo.git</connection>
+    <url>https://example.com/repo</url>
+  </scm>
+  <properties>
+    <java.version>11</java.version>
+    <guava.version>30.0-jre</guava.version>
+  </properties>
+  <dependencyManagement>
+    <dependencies>
+      <dependency>
+        <groupId>com.google.guava</groupId>
+        <artifactId>guava</artifactId>
+        <version>${guava.version}</version>
+      </dependency>
+      <dependency>
+        <groupId>junit</groupId>
+        <artifactId>junit</artifactId>
+        <version>4.13</version>
+        <scope>test</scope>
+      </dependency>
+    </dependencies>
+  </dependencyManagement>
+  <repositories>
+    <repository>
+      <id>example</id>
+      <url>https://repo.example.com/releases</url>
+    </repository>
+  </repositories>
+  <build>
+    <plugins>
+      <plugin>
+        <artifactId>maven-compiler-plugin</artifactId>
+        <version>3.8.1</version>
+        <configuration>
+          <release>${java.version}</release>
+        </configuration>
+      </plugin>
+      <plugin>
+        <artifactId>maven-enforcer-plugin</artifactId>
+        <version>3.0.0</version>
+        <inherited>false</inherited>
+      </plugin>
+    </plugins>
+  </build>
+  <profiles>
+    <profile>
+      <id>release</id>
+    </profile>
+  </profiles>
+</project>`
+
+const parentPOM = `<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <modelVersion>4.0.0</modelVersion>
+  <parent>
+    <groupId>com.example</groupId>
+    <artifactId>grandparent</artifactId>
+    <version>1</version>
+  </parent>
+  <artifactId>parent</artifactId>
+  <version>2.0.0</version>
+  <packaging>pom</packaging>
+  <properties>
+    <guava.version>31.1-jre</guava.version>
+  </properties>
+  <dependencyManagement>
+    <dependencies>
+      <dependency>
+        <groupId>junit</groupId>
+        <artifactId>junit</artifactId>
+        <version>4.13.2</version>
+        <scope>test</scope>
+      </dependency>
+    </dependencies>
+  </dependencyManagement>
+  <repositories>
+    <repository>
+      <id>central</id>
+      <url>https://mirror.example.com/maven2</url>
+    </repository>
+  </repositories>
+</project>`
+
+const childPOM = `<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <modelVersion>4.0.0</modelVersion>
+  <parent>
+    <groupId>com.example</groupId>
+    <artifactId>parent</artifactId>
+    <version>2.0.0</version>
+  </parent>
+  <artifactId>child</artifactId>
+  <properties>
+    <java.version>17</java.version>
+  </properties>
+  <dependencies>
+    <dependency>
+      <groupId>com.google.guava</groupId>
+      <artifactId>guava</artifactId>
+    </dependency>
+    <dependency>
+      <groupId>${project.groupId}</groupId>
+      <artifactId>sibling</artifactId>
+      <version>${project.version}</version>
+    </dependency>
+  </dependencies>
+  <build>
+    <plugins>
+      <plugin>
+        <artifactId>maven-compiler-plugin</artifactId>
+      </plugin>
+    </plugins>
+  </build>
+</project>`
+
+// pomRepo serves the POMs, keyed by path within the repository, and their sha1 checksums
+func pomRepo(poms map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if p, ok := poms[strings.TrimSuffix(path, ".sha1")]; ok {
+			if strings.HasSuffix(path, ".sha1") {
+				h := sha1.Sum([]byte(p))
+				w.Write([]byte(hex.EncodeToString(h[:])))
+				return
+			}
+			w.Write([]byte(p))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestEffective(t *testing.T) {
+	s := pomRepo(map[string]string{
+		"com/example/grandparent/1/grandparent-1.pom": grandparentPOM,
+		"com/example/parent/2.0.0/parent-2.0.0.pom":   parentPOM,
+		"com/example/child/2.0.0/child-2.0.0.pom":     childPOM,
+		"com/example/cyclic/1/cyclic-1.pom":           strings.Replace(strings.Replace(parentPOM, ">grandparent<", ">cyclic<", 1), ">parent<", ">cyclic<", 1),
+		"com/example/orphan/1/orphan-1.pom":           strings.Replace(childPOM, ">parent<", ">missing<", 1),
+	})
+	defer s.Close()
+
+	p, err := Effective(s.URL, "com.example", "child", "2.0.0", nil, nil)
+	if err != nil {
+		t.Fatalf("error getting effective POM: %v", err)
+	}
+	assert.Equal(t, "com.example", p.GroupID, "groupId should be inherited")
+	assert.Equal(t, "2.0.0", p.Version, "version should be inherited")
+	assert.Equal(t, "", p.Name, "name should not be inherited")
+	assert.Equal(t, "", p.Packaging, "packaging should not be inherited")
+	assert.Nil(t, p.Profiles, "profiles should not be inherited")
+	assert.Equal(t, "Shared build", p.Description)
+	assert.Equal(t, "https://example.com/parent/child", p.URL, "artifactIds should be appended to the inherited URL")
+	assert.Equal(t, "scm:git:https://example.com/repo.git/parent/child", p.SCM.Connection)
+	assert.Equal(t, "Apache-2.0", (*p.Licenses)[0].Name)
+
+	v, _ := p.Properties.Get("java.version")
+	assert.Equal(t, "17", v, "child properties should override the parents'")
+	v, _ = p.Properties.Get("guava.version")
+	assert.Equal(t, "31.1-jre", v)
+
+	dm := *p.DependencyManagement.Dependencies
+	if assert.Equal(t, 2, len(dm)) {
+		assert.Equal(t, "junit", dm[0].ArtifactID)
+		assert.Equal(t, "4.13.2", dm[0].Version, "nearer dependencyManagement should win")
+		assert.Equal(t, "guava", dm[1].ArtifactID)
+		assert.Equal(t, "31.1-jre", dm[1].Version, "managed version should be interpolated with the child's properties")
+	}
+	deps := *p.Dependencies
+	assert.Equal(t, "com.example", deps[1].GroupID)
+	assert.Equal(t, "2.0.0", deps[1].Version)
+
+	repos := *p.Repositories
+	if assert.Equal(t, 2, len(repos)) {
+		assert.Equal(t, "central", repos[0].ID)
+		assert.Equal(t, "https://mirror.example.com/maven2", repos[0].URL, "central should be overridden by id")
+		assert.Equal(t, "example", repos[1].ID)
+	}
+	assert.Equal(t, centralURL, (*p.PluginRepositories)[0].URL, "super POM plugin repository should be inherited")
+
+	plugins := *p.Build.Plugins
+	if assert.Equal(t, 1, len(plugins), "plugins not inherited should be dropped") {
+		assert.Equal(t, "3.8.1", plugins[0].Version)
+		assert.Contains(t, plugins[0].Configuration.InnerXML, "<release>17</release>")
+	}
+
+	_, err = Effective(s.URL, "com.example", "cyclic", "1", nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cycle")
+	}
+	_, err = Effective(s.URL, "com.example", "orphan", "1", nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "com.example:missing:2.0.0")
+	}
+}
+
+func TestLoadEffective(t *testing.T) {
+	s := pomRepo(map[string]string{
+		"com/example/grandparent/1/grandparent-1.pom": grandparentPOM,
+	})
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "gomvn-effective")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	child := filepath.Join(dir, "child")
+	if err := os.Mkdir(child, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the parent is found at the default relative path, its parent in the repository
+	ioutil.WriteFile(filepath.Join(dir, pomFile), []byte(parentPOM), 0644)
+	ioutil.WriteFile(filepath.Join(child, pomFile), []byte(strings.Replace(childPOM,
+		"<build>", "<build><directory>${project.basedir}/target</directory>", 1)), 0644)
+
+	p, err := LoadEffective(filepath.Join(child, pomFile), s.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("error loading effective POM: %v", err)
+	}
+	assert.Equal(t, "2.0.0", p.Version)
+	v, _ := p.Properties.Get("guava.version")
+	assert.Equal(t, "31.1-jre", v, "properties of the local parent should be inherited")
+	assert.Equal(t, filepath.Join(child, "target"), p.Build.Directory)
+
+	// a POM at the relative path that is not the parent is ignored
+	ioutil.WriteFile(filepath.Join(dir, pomFile), []byte(strings.Replace(parentPOM, "2.0.0", "3.0.0", 1)), 0644)
+	_, err = LoadEffective(filepath.Join(child, pomFile), s.URL, nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "error getting parent com.example:parent:2.0.0")
+	}
+}
@@ -0,0 +1,303 @@
+package pom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var expression = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+var (
+	nameType     = reflect.TypeOf(xml.Name{})
+	propertyType = reflect.TypeOf(Property{})
+	rawType      = reflect.TypeOf(Raw{})
+)
+
+// InterpolationError is returned when some expressions of a POM could not be interpolated, either because nothing
+// defines them or because they are defined in terms of each other. Such expressions are left as they are.
+type InterpolationError struct {
+	ErrorString string
+	// Unresolved are the expressions with no value
+	Unresolved []string
+	// Cycles are the chains of expressions that refer back to themselves, such as "a -> b -> a"
+	Cycles []string
+}
+
+func (e InterpolationError) Error() string {
+	return e.ErrorString
+}
+
+// Interpolate returns a copy of the POM with the ${...} expressions in its values, including plugin configuration,
+// replaced. As with maven an expression is resolved from, in order:
+//   - the fields of the model when prefixed with project. (or the deprecated pom.), such as ${project.version} or
+//     ${project.parent.groupId}. The groupId and version of the project default to those of its parent.
+//   - the properties given, such as the -D user properties of maven or ${project.basedir}
+//   - the properties of the POM
+//   - environment variables when prefixed with env.
+//   - the fields of the model without a prefix, such as the deprecated ${version}
+//
+// The values found are themselves interpolated. The POM is returned interpolated as far as possible along with an
+// InterpolationError if any expression could not be resolved.
+func (p *POM) Interpolate(props map[string]string) (POM, error) {
+	in := &interpolator{
+		model:      p,
+		props:      props,
+		values:     make(map[string]string),
+		resolving:  make(map[string]bool),
+		cyclic:     make(map[string]bool),
+		unresolved: make(map[string]bool),
+	}
+	ip := in.copy(reflect.ValueOf(*p)).Interface().(POM)
+	return ip, in.err()
+}
+
+type interpolator struct {
+	model *POM
+	props map[string]string
+	// values are the resolved expressions
+	values map[string]string
+	// resolving are the expressions being resolved, in order, to detect cycles
+	resolving map[string]bool
+	stack     []string
+	// cyclic are the expressions that refer back to themselves
+	cyclic     map[string]bool
+	unresolved map[string]bool
+	cycles     []string
+}
+
+func (in *interpolator) err() error {
+	var unresolved []string
+	for expr := range in.unresolved {
+		unresolved = append(unresolved, expr)
+	}
+	return newInterpolationError(unresolved, in.cycles)
+}
+
+// newInterpolationError returns the InterpolationError of the unresolved and cyclic expressions, nil if there are none
+func newInterpolationError(unresolved, cycles []string) error {
+	if len(unresolved) == 0 && len(cycles) == 0 {
+		return nil
+	}
+	e := InterpolationError{Unresolved: unresolved, Cycles: cycles}
+	sort.Strings(e.Unresolved)
+	var msgs []string
+	if len(e.Unresolved) > 0 {
+		msgs = append(msgs, "unresolved expressions: ${"+strings.Join(e.Unresolved, "}, ${")+"}")
+	}
+	if len(e.Cycles) > 0 {
+		msgs = append(msgs, "cyclic expressions: "+strings.Join(e.Cycles, ", "))
+	}
+	e.ErrorString = fmt.Sprintf("could not interpolate POM: %s", strings.Join(msgs, "; "))
+	return e
+}
+
+// joinInterpolationErrors returns an InterpolationError of the expressions of all the errors, nil if none of them are
+// an InterpolationError
+func joinInterpolationErrors(errs ...error) error {
+	var unresolved, cycles []string
+	seen := make(map[string]bool)
+	for _, err := range errs {
+		e, ok := err.(InterpolationError)
+		if !ok {
+			continue
+		}
+		for _, expr := range e.Unresolved {
+			if !seen["${"+expr+"}"] {
+				seen["${"+expr+"}"] = true
+				unresolved = append(unresolved, expr)
+			}
+		}
+		for _, c := range e.Cycles {
+			if !seen[c] {
+				seen[c] = true
+				cycles = append(cycles, c)
+			}
+		}
+	}
+	return newInterpolationError(unresolved, cycles)
+}
+
+// interpolate replaces the expressions in the string, escaping their values for XML if escape is true
+func (in *interpolator) interpolate(s string, escape bool) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return expression.ReplaceAllStringFunc(s, func(m string) string {
+		v, ok := in.resolve(m[2 : len(m)-1])
+		if !ok {
+			return m
+		}
+		if escape {
+			buf := new(bytes.Buffer)
+			xml.EscapeText(buf, []byte(v))
+			return buf.String()
+		}
+		return v
+	})
+}
+
+// resolve returns the interpolated value of the expression
+func (in *interpolator) resolve(expr string) (string, bool) {
+	if v, ok := in.values[expr]; ok {
+		return v, true
+	}
+	if in.cyclic[expr] {
+		return "", false
+	}
+	if in.resolving[expr] {
+		var i int
+		for i = range in.stack {
+			if in.stack[i] == expr {
+				break
+			}
+		}
+		// none of the expressions in the cycle can be resolved
+		for _, e := range in.stack[i:] {
+			in.cyclic[e] = true
+		}
+		in.cycles = append(in.cycles, strings.Join(append(append([]string{}, in.stack[i:]...), expr), " -> "))
+		return "", false
+	}
+	raw, ok := in.lookup(expr)
+	if !ok {
+		in.unresolved[expr] = true
+		return "", false
+	}
+	in.resolving[expr] = true
+	in.stack = append(in.stack, expr)
+	v := in.interpolate(raw, false)
+	in.stack = in.stack[:len(in.stack)-1]
+	delete(in.resolving, expr)
+	if in.cyclic[expr] {
+		return "", false
+	}
+	in.values[expr] = v
+	return v, true
+}
+
+// lookup returns the uninterpolated value of the expression
+func (in *interpolator) lookup(expr string) (string, bool) {
+	for _, prefix := range []string{"project.", "pom."} {
+		if strings.HasPrefix(expr, prefix) {
+			if v, ok := in.field(strings.TrimPrefix(expr, prefix)); ok {
+				return v, true
+			}
+		}
+	}
+	if v, ok := in.props[expr]; ok {
+		return v, true
+	}
+	if v, ok := in.model.Properties.Get(expr); ok {
+		return v, true
+	}
+	if strings.HasPrefix(expr, "env.") {
+		if v, ok := os.LookupEnv(strings.TrimPrefix(expr, "env.")); ok {
+			return v, true
+		}
+	}
+	return in.field(expr)
+}
+
+// field returns the value of the field of the model at the path of element names, such as parent.groupId
+func (in *interpolator) field(path string) (string, bool) {
+	switch path {
+	case "groupId", "version":
+		// inherited from the parent if not set
+		if v, ok := modelField(reflect.ValueOf(*in.model), strings.Split(path, ".")); ok {
+			return v, true
+		}
+		if in.model.Parent == nil {
+			return "", false
+		}
+		return modelField(reflect.ValueOf(*in.model.Parent), strings.Split(path, "."))
+	}
+	return modelField(reflect.ValueOf(*in.model), strings.Split(path, "."))
+}
+
+// modelField returns the non empty string value of the field at the path of element names in the struct
+func modelField(v reflect.Value, path []string) (string, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if len(path) == 0 {
+		if v.Kind() == reflect.String && v.String() != "" {
+			return v.String(), true
+		}
+		return "", false
+	}
+	if v.Kind() != reflect.Struct {
+		return "", false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			if s, ok := modelField(v.Field(i), path); ok {
+				return s, true
+			}
+			continue
+		}
+		name := strings.Split(f.Tag.Get("xml"), ",")[0]
+		if strings.Contains(name, ">") || name != path[0] {
+			continue
+		}
+		return modelField(v.Field(i), path[1:])
+	}
+	return "", false
+}
+
+// copy returns a copy of the value with the strings in it interpolated
+func (in *interpolator) copy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		c := reflect.New(v.Type()).Elem()
+		c.SetString(in.interpolate(v.String(), false))
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(in.copy(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(in.copy(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		switch v.Type() {
+		case nameType:
+			return c
+		case propertyType:
+			c.FieldByName("Value").Set(in.copy(v.FieldByName("Value")))
+			return c
+		case rawType:
+			c.FieldByName("Attrs").Set(in.copy(v.FieldByName("Attrs")))
+			c.FieldByName("InnerXML").SetString(in.interpolate(v.FieldByName("InnerXML").String(), true))
+			return c
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(in.copy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
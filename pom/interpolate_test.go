@@ -0,0 +1,99 @@
+package pom
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const interpolatePOM = `<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <modelVersion>4.0.0</modelVersion>
+  <parent>
+    <groupId>com.example</groupId>
+    <artifactId>parent</artifactId>
+    <version>${revision}</version>
+  </parent>
+  <artifactId>child</artifactId>
+  <name>${project.artifactId} &amp; friends</name>
+  <url>https://example.com/${project.parent.artifactId}/${project.artifactId}</url>
+  <properties>
+    <revision>1.2.3</revision>
+    <guava.version>31.1-jre</guava.version>
+    <lib.version>${guava.version}</lib.version>
+  </properties>
+  <dependencies>
+    <dependency>
+      <groupId>${project.groupId}</groupId>
+      <artifactId>sibling</artifactId>
+      <version>${project.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>com.google.guava</groupId>
+      <artifactId>guava</artifactId>
+      <version>${lib.version}</version>
+    </dependency>
+  </dependencies>
+  <build>
+    <finalName>${project.build.finalName.prefix}-${version}</finalName>
+    <plugins>
+      <plugin>
+        <artifactId>maven-jar-plugin</artifactId>
+        <configuration>
+          <home>${env.GOMVN_TEST_HOME}</home>
+          <encoding>${project.build.sourceEncoding}</encoding>
+        </configuration>
+      </plugin>
+    </plugins>
+  </build>
+</project>`
+
+func TestPOM_Interpolate(t *testing.T) {
+	os.Setenv("GOMVN_TEST_HOME", "/home/<test>")
+	defer os.Unsetenv("GOMVN_TEST_HOME")
+	var p POM
+	if err := p.Unmarshal([]byte(interpolatePOM)); err != nil {
+		t.Fatal(err)
+	}
+	ip, err := p.Interpolate(map[string]string{
+		"project.build.sourceEncoding":   "UTF-8",
+		"project.build.finalName.prefix": "app",
+		"revision":                       "2.0.0",
+	})
+	if err != nil {
+		t.Fatalf("error interpolating: %v", err)
+	}
+	assert.Equal(t, "2.0.0", ip.Parent.Version, "given properties should override those of the POM")
+	assert.Equal(t, "child & friends", ip.Name)
+	assert.Equal(t, "https://example.com/parent/child", ip.URL)
+	deps := *ip.Dependencies
+	assert.Equal(t, "com.example", deps[0].GroupID, "groupId should be inherited from the parent")
+	assert.Equal(t, "2.0.0", deps[0].Version, "version should be inherited from the parent")
+	assert.Equal(t, "31.1-jre", deps[1].Version)
+	assert.Equal(t, "app-2.0.0", ip.Build.FinalName)
+	assert.Contains(t, (*ip.Build.Plugins)[0].Configuration.InnerXML, "<home>/home/&lt;test&gt;</home>", "configuration values should be escaped")
+	assert.Contains(t, (*ip.Build.Plugins)[0].Configuration.InnerXML, "<encoding>UTF-8</encoding>")
+
+	// the original is unchanged
+	assert.Equal(t, "${revision}", p.Parent.Version)
+	assert.Equal(t, "${lib.version}", (*p.Dependencies)[1].Version)
+	assert.Contains(t, (*p.Build.Plugins)[0].Configuration.InnerXML, "${env.GOMVN_TEST_HOME}")
+}
+
+func TestPOM_Interpolate_Errors(t *testing.T) {
+	p := New("com.example", "example", "${a}", "jar")
+	p.Name = "${missing} ${b}"
+	p.Description = "${c}"
+	p.Properties.Set("a", "${b}")
+	p.Properties.Set("b", "${a}")
+	p.Properties.Set("c", "${c}")
+	ip, err := p.Interpolate(nil)
+	e, ok := err.(InterpolationError)
+	if !ok {
+		t.Fatalf("expected InterpolationError, got: %v", err)
+	}
+	assert.Equal(t, []string{"missing"}, e.Unresolved)
+	assert.Equal(t, []string{"a -> b -> a", "c -> c"}, e.Cycles)
+	assert.Equal(t, "${missing} ${b}", ip.Name, "unresolved expressions should be left as they are")
+	assert.Equal(t, "com.example", ip.GroupID)
+}
@@ -0,0 +1,311 @@
+package pom
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+const (
+	namespace      = "http://maven.apache.org/POM/4.0.0"
+	xsiNamespace   = "http://www.w3.org/2001/XMLSchema-instance"
+	schemaLocation = namespace + " https://maven.apache.org/xsd/maven-4.0.0.xsd"
+)
+
+// Parent is the POM the project inherits from
+type Parent struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	// RelativePath is nil if not set, in which case maven looks for the parent in ../pom.xml, and empty if set empty
+	// to look for the parent only in the repositories.
+	RelativePath *string `xml:"relativePath,omitempty"`
+}
+
+type Organization struct {
+	Name string `xml:"name,omitempty"`
+	URL  string `xml:"url,omitempty"`
+}
+
+type Contributor struct {
+	Name            string     `xml:"name,omitempty"`
+	Email           string     `xml:"email,omitempty"`
+	URL             string     `xml:"url,omitempty"`
+	Organization    string     `xml:"organization,omitempty"`
+	OrganizationURL string     `xml:"organizationUrl,omitempty"`
+	Roles           *[]string  `xml:"roles>role,omitempty"`
+	Timezone        string     `xml:"timezone,omitempty"`
+	Properties      Properties `xml:"properties,omitempty"`
+}
+
+type Developer struct {
+	ID string `xml:"id,omitempty"`
+	Contributor
+}
+
+type MailingList struct {
+	Name          string    `xml:"name,omitempty"`
+	Subscribe     string    `xml:"subscribe,omitempty"`
+	Unsubscribe   string    `xml:"unsubscribe,omitempty"`
+	Post          string    `xml:"post,omitempty"`
+	Archive       string    `xml:"archive,omitempty"`
+	OtherArchives *[]string `xml:"otherArchives>otherArchive,omitempty"`
+}
+
+type Prerequisites struct {
+	Maven string `xml:"maven,omitempty"`
+}
+
+type SCM struct {
+	ChildSCMConnectionInheritAppendPath          string `xml:"child.scm.connection.inherit.append.path,attr,omitempty"`
+	ChildSCMDeveloperConnectionInheritAppendPath string `xml:"child.scm.developerConnection.inherit.append.path,attr,omitempty"`
+	ChildSCMURLInheritAppendPath                 string `xml:"child.scm.url.inherit.append.path,attr,omitempty"`
+	Connection                                   string `xml:"connection,omitempty"`
+	DeveloperConnection                          string `xml:"developerConnection,omitempty"`
+	Tag                                          string `xml:"tag,omitempty"`
+	URL                                          string `xml:"url,omitempty"`
+}
+
+type IssueManagement struct {
+	System string `xml:"system,omitempty"`
+	URL    string `xml:"url,omitempty"`
+}
+
+type CIManagement struct {
+	System    string      `xml:"system,omitempty"`
+	URL       string      `xml:"url,omitempty"`
+	Notifiers *[]Notifier `xml:"notifiers>notifier,omitempty"`
+}
+
+type Notifier struct {
+	Type          string     `xml:"type,omitempty"`
+	SendOnError   string     `xml:"sendOnError,omitempty"`
+	SendOnFailure string     `xml:"sendOnFailure,omitempty"`
+	SendOnSuccess string     `xml:"sendOnSuccess,omitempty"`
+	SendOnWarning string     `xml:"sendOnWarning,omitempty"`
+	Address       string     `xml:"address,omitempty"`
+	Configuration Properties `xml:"configuration,omitempty"`
+}
+
+type DistributionManagement struct {
+	Repository         *DeploymentRepository `xml:"repository,omitempty"`
+	SnapshotRepository *DeploymentRepository `xml:"snapshotRepository,omitempty"`
+	Site               *Site                 `xml:"site,omitempty"`
+	DownloadURL        string                `xml:"downloadUrl,omitempty"`
+	Relocation         *Relocation           `xml:"relocation,omitempty"`
+	Status             string                `xml:"status,omitempty"`
+}
+
+// DeploymentRepository is a repository the project is deployed to
+type DeploymentRepository struct {
+	UniqueVersion string      `xml:"uniqueVersion,omitempty"`
+	Releases      *RepoPolicy `xml:"releases,omitempty"`
+	Snapshots     *RepoPolicy `xml:"snapshots,omitempty"`
+	ID            string      `xml:"id,omitempty"`
+	Name          string      `xml:"name,omitempty"`
+	URL           string      `xml:"url,omitempty"`
+	Layout        string      `xml:"layout,omitempty"`
+}
+
+type Site struct {
+	ChildSiteURLInheritAppendPath string `xml:"child.site.url.inherit.append.path,attr,omitempty"`
+	ID                            string `xml:"id,omitempty"`
+	Name                          string `xml:"name,omitempty"`
+	URL                           string `xml:"url,omitempty"`
+}
+
+// Relocation is the new coordinates of a project that has moved
+type Relocation struct {
+	GroupID    string `xml:"groupId,omitempty"`
+	ArtifactID string `xml:"artifactId,omitempty"`
+	Version    string `xml:"version,omitempty"`
+	Message    string `xml:"message,omitempty"`
+}
+
+type DependencyManagement struct {
+	Dependencies *[]Dependency `xml:"dependencies>dependency,omitempty"`
+}
+
+// Exclusion is a transitive dependency excluded from a dependency. Either id may be the wildcard *.
+type Exclusion struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+}
+
+// BuildBase is the build configuration that can also be set in a profile
+type BuildBase struct {
+	DefaultGoal      string            `xml:"defaultGoal,omitempty"`
+	Resources        *[]Resource       `xml:"resources>resource,omitempty"`
+	TestResources    *[]Resource       `xml:"testResources>testResource,omitempty"`
+	Directory        string            `xml:"directory,omitempty"`
+	FinalName        string            `xml:"finalName,omitempty"`
+	Filters          *[]string         `xml:"filters>filter,omitempty"`
+	PluginManagement *PluginManagement `xml:"pluginManagement,omitempty"`
+	Plugins          *[]Plugin         `xml:"plugins>plugin,omitempty"`
+}
+
+type Build struct {
+	SourceDirectory       string       `xml:"sourceDirectory,omitempty"`
+	ScriptSourceDirectory string       `xml:"scriptSourceDirectory,omitempty"`
+	TestSourceDirectory   string       `xml:"testSourceDirectory,omitempty"`
+	OutputDirectory       string       `xml:"outputDirectory,omitempty"`
+	TestOutputDirectory   string       `xml:"testOutputDirectory,omitempty"`
+	Extensions            *[]Extension `xml:"extensions>extension,omitempty"`
+	BuildBase
+}
+
+// Extension is an artifact added to the build's classpath
+type Extension struct {
+	GroupID    string `xml:"groupId,omitempty"`
+	ArtifactID string `xml:"artifactId,omitempty"`
+	Version    string `xml:"version,omitempty"`
+}
+
+type Resource struct {
+	TargetPath string    `xml:"targetPath,omitempty"`
+	Filtering  string    `xml:"filtering,omitempty"`
+	Directory  string    `xml:"directory,omitempty"`
+	Includes   *[]string `xml:"includes>include,omitempty"`
+	Excludes   *[]string `xml:"excludes>exclude,omitempty"`
+}
+
+type PluginManagement struct {
+	Plugins *[]Plugin `xml:"plugins>plugin,omitempty"`
+}
+
+type Plugin struct {
+	GroupID       string             `xml:"groupId,omitempty"`
+	ArtifactID    string             `xml:"artifactId"`
+	Version       string             `xml:"version,omitempty"`
+	Extensions    string             `xml:"extensions,omitempty"`
+	Executions    *[]PluginExecution `xml:"executions>execution,omitempty"`
+	Dependencies  *[]Dependency      `xml:"dependencies>dependency,omitempty"`
+	Goals         *Raw               `xml:"goals,omitempty"`
+	Inherited     string             `xml:"inherited,omitempty"`
+	Configuration *Raw               `xml:"configuration,omitempty"`
+}
+
+type PluginExecution struct {
+	ID            string    `xml:"id,omitempty"`
+	Phase         string    `xml:"phase,omitempty"`
+	Goals         *[]string `xml:"goals>goal,omitempty"`
+	Inherited     string    `xml:"inherited,omitempty"`
+	Configuration *Raw      `xml:"configuration,omitempty"`
+}
+
+type Reporting struct {
+	ExcludeDefaults string          `xml:"excludeDefaults,omitempty"`
+	OutputDirectory string          `xml:"outputDirectory,omitempty"`
+	Plugins         *[]ReportPlugin `xml:"plugins>plugin,omitempty"`
+}
+
+type ReportPlugin struct {
+	GroupID       string       `xml:"groupId,omitempty"`
+	ArtifactID    string       `xml:"artifactId"`
+	Version       string       `xml:"version,omitempty"`
+	ReportSets    *[]ReportSet `xml:"reportSets>reportSet,omitempty"`
+	Inherited     string       `xml:"inherited,omitempty"`
+	Configuration *Raw         `xml:"configuration,omitempty"`
+}
+
+type ReportSet struct {
+	ID            string    `xml:"id,omitempty"`
+	Reports       *[]string `xml:"reports>report,omitempty"`
+	Inherited     string    `xml:"inherited,omitempty"`
+	Configuration *Raw      `xml:"configuration,omitempty"`
+}
+
+// Profile is a set of changes to the POM that can be activated
+type Profile struct {
+	ID                     string                  `xml:"id,omitempty"`
+	Activation             *Activation             `xml:"activation,omitempty"`
+	Build                  *BuildBase              `xml:"build,omitempty"`
+	Modules                *[]string               `xml:"modules>module,omitempty"`
+	DistributionManagement *DistributionManagement `xml:"distributionManagement,omitempty"`
+	Properties             Properties              `xml:"properties,omitempty"`
+	DependencyManagement   *DependencyManagement   `xml:"dependencyManagement,omitempty"`
+	Dependencies           *[]Dependency           `xml:"dependencies>dependency,omitempty"`
+	Repositories           *[]Repository           `xml:"repositories>repository,omitempty"`
+	PluginRepositories     *[]Repository           `xml:"pluginRepositories>pluginRepository,omitempty"`
+	Reports                *Raw                    `xml:"reports,omitempty"`
+	Reporting              *Reporting              `xml:"reporting,omitempty"`
+}
+
+// Activation is the conditions that activate a profile
+type Activation struct {
+	ActiveByDefault string              `xml:"activeByDefault,omitempty"`
+	JDK             string              `xml:"jdk,omitempty"`
+	OS              *ActivationOS       `xml:"os,omitempty"`
+	Property        *ActivationProperty `xml:"property,omitempty"`
+	File            *ActivationFile     `xml:"file,omitempty"`
+}
+
+type ActivationOS struct {
+	Name    string `xml:"name,omitempty"`
+	Family  string `xml:"family,omitempty"`
+	Arch    string `xml:"arch,omitempty"`
+	Version string `xml:"version,omitempty"`
+}
+
+type ActivationProperty struct {
+	Name  string `xml:"name"`
+	Value string `xml:"value,omitempty"`
+}
+
+type ActivationFile struct {
+	Missing string `xml:"missing,omitempty"`
+	Exists  string `xml:"exists,omitempty"`
+}
+
+// Raw is an element kept as written, such as the free form configuration of a plugin
+type Raw struct {
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXML string     `xml:",innerxml"`
+}
+
+// IsOptional returns if the dependency is optional
+func (d Dependency) IsOptional() bool {
+	return strings.TrimSpace(d.Optional) == "true"
+}
+
+// IsEnabled returns if the policy is enabled, which it is unless set otherwise
+func (r RepoPolicy) IsEnabled() bool {
+	return strings.TrimSpace(r.Enabled) != "false"
+}
+
+// MarshalXML writes the project element with the POM namespace and schema location
+func (p POM) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	// project has the fields of POM without its methods
+	type project POM
+	start.Name = xml.Name{Local: "project"}
+	ns := p.XMLName.Space
+	if ns == "" && p.SchemaLocation != "" {
+		ns = namespace
+	}
+	if ns != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: ns})
+	}
+	if p.SchemaLocation != "" {
+		start.Attr = append(start.Attr,
+			xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace},
+			xml.Attr{Name: xml.Name{Local: "xsi:schemaLocation"}, Value: p.SchemaLocation},
+		)
+	}
+	p.XMLName = xml.Name{}
+	return e.EncodeElement(project(p), start)
+}
+
+// UnmarshalXML reads the project element, keeping its schema location
+func (p *POM) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type project POM
+	var pp project
+	if err := d.DecodeElement(&pp, &start); err != nil {
+		return err
+	}
+	*p = POM(pp)
+	for _, a := range start.Attr {
+		if a.Name.Space == xsiNamespace && a.Name.Local == "schemaLocation" {
+			p.SchemaLocation = a.Value
+		}
+	}
+	return nil
+}
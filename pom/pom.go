@@ -2,6 +2,7 @@ package pom
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
@@ -17,58 +18,103 @@ const (
 	modelVersion = "4.0.0"
 )
 
+// POM is the maven project object model, version 4.0.0
 type POM struct {
-	XMLName      xml.Name      `xml:"project"`
-	ModelVersion string        `xml:"modelVersion"`
-	GroupID      string        `xml:"groupId"`
-	ArtifactID   string        `xml:"artifactId"`
-	Version      string        `xml:"version"`
-	Packaging    string        `xml:"packaging"`
-	Description  string        `xml:"description,omitempty"`
-	URL          string        `xml:"url,omitempty"`
-	Name         string        `xml:"name,omitempty"`
-	Licenses     *[]License    `xml:"licenses>license,omitempty"`
-	Dependencies *[]Dependency `xml:"dependencies>dependency,omitempty"`
-	Repositories *[]Repository `xml:"repositories>repository,omitempty"`
+	XMLName xml.Name `xml:"project"`
+	// SchemaLocation is the xsi:schemaLocation of the project element
+	SchemaLocation                   string                  `xml:"-"`
+	ChildProjectURLInheritAppendPath string                  `xml:"child.project.url.inherit.append.path,attr,omitempty"`
+	ModelVersion                     string                  `xml:"modelVersion"`
+	Parent                           *Parent                 `xml:"parent,omitempty"`
+	GroupID                          string                  `xml:"groupId,omitempty"`
+	ArtifactID                       string                  `xml:"artifactId"`
+	Version                          string                  `xml:"version,omitempty"`
+	Packaging                        string                  `xml:"packaging,omitempty"`
+	Name                             string                  `xml:"name,omitempty"`
+	Description                      string                  `xml:"description,omitempty"`
+	URL                              string                  `xml:"url,omitempty"`
+	InceptionYear                    string                  `xml:"inceptionYear,omitempty"`
+	Organization                     *Organization           `xml:"organization,omitempty"`
+	Licenses                         *[]License              `xml:"licenses>license,omitempty"`
+	Developers                       *[]Developer            `xml:"developers>developer,omitempty"`
+	Contributors                     *[]Contributor          `xml:"contributors>contributor,omitempty"`
+	MailingLists                     *[]MailingList          `xml:"mailingLists>mailingList,omitempty"`
+	Prerequisites                    *Prerequisites          `xml:"prerequisites,omitempty"`
+	Modules                          *[]string               `xml:"modules>module,omitempty"`
+	SCM                              *SCM                    `xml:"scm,omitempty"`
+	IssueManagement                  *IssueManagement        `xml:"issueManagement,omitempty"`
+	CIManagement                     *CIManagement           `xml:"ciManagement,omitempty"`
+	DistributionManagement           *DistributionManagement `xml:"distributionManagement,omitempty"`
+	Properties                       Properties              `xml:"properties,omitempty"`
+	DependencyManagement             *DependencyManagement   `xml:"dependencyManagement,omitempty"`
+	Dependencies                     *[]Dependency           `xml:"dependencies>dependency,omitempty"`
+	Repositories                     *[]Repository           `xml:"repositories>repository,omitempty"`
+	PluginRepositories               *[]Repository           `xml:"pluginRepositories>pluginRepository,omitempty"`
+	Build                            *Build                  `xml:"build,omitempty"`
+	Reports                          *Raw                    `xml:"reports,omitempty"`
+	Reporting                        *Reporting              `xml:"reporting,omitempty"`
+	Profiles                         *[]Profile              `xml:"profiles>profile,omitempty"`
 }
 
 type License struct {
-	Name         string `xml:"name"`
-	URL          string `xml:"url"`
-	Distribution string `xml:"distribution"`
+	Name         string `xml:"name,omitempty"`
+	URL          string `xml:"url,omitempty"`
+	Distribution string `xml:"distribution,omitempty"`
+	Comments     string `xml:"comments,omitempty"`
 }
 
 type Dependency struct {
-	GroupID    string `xml:"groupId"`
-	ArtifactID string `xml:"artifactId"`
-	Version    string `xml:"version"`
-	Type       string `xml:"type"`
-	Scope      string `xml:"scope"`
-	Optional   bool   `xml:"optional"`
+	GroupID    string       `xml:"groupId"`
+	ArtifactID string       `xml:"artifactId"`
+	Version    string       `xml:"version,omitempty"`
+	Type       string       `xml:"type,omitempty"`
+	Classifier string       `xml:"classifier,omitempty"`
+	Scope      string       `xml:"scope,omitempty"`
+	SystemPath string       `xml:"systemPath,omitempty"`
+	Exclusions *[]Exclusion `xml:"exclusions>exclusion,omitempty"`
+	// Optional is kept as written, which may be an expression, see IsOptional
+	Optional string `xml:"optional,omitempty"`
 }
 
 type Repository struct {
-	ID        string     `xml:"id"`
-	Name      string     `xml:"name"`
-	URL       string     `xml:"url"`
-	Layout    string     `xml:"layout"`
-	Snapshots RepoPolicy `xml:"snapshots"`
-	Releases  RepoPolicy `xml:"releases"`
+	ID        string      `xml:"id"`
+	Name      string      `xml:"name,omitempty"`
+	URL       string      `xml:"url"`
+	Layout    string      `xml:"layout,omitempty"`
+	Snapshots *RepoPolicy `xml:"snapshots,omitempty"`
+	Releases  *RepoPolicy `xml:"releases,omitempty"`
 }
 
 type RepoPolicy struct {
-	Enabled        bool   `xml:"enabled"`
-	UpdatePolicy   string `xml:"updatePolicy"`
-	ChecksumPolicy string `xml:"checksumPolicy"`
+	// Enabled is kept as written, which may be an expression, see IsEnabled
+	Enabled        string `xml:"enabled,omitempty"`
+	UpdatePolicy   string `xml:"updatePolicy,omitempty"`
+	ChecksumPolicy string `xml:"checksumPolicy,omitempty"`
 }
 
 func New(groupID, artifactID, version, packaging string) POM {
 	return POM{
-		ModelVersion: modelVersion,
-		GroupID:      groupID,
-		ArtifactID:   artifactID,
-		Version:      version,
-		Packaging:    packaging,
+		XMLName:        xml.Name{Space: namespace, Local: "project"},
+		SchemaLocation: schemaLocation,
+		ModelVersion:   modelVersion,
+		GroupID:        groupID,
+		ArtifactID:     artifactID,
+		Version:        version,
+		Packaging:      packaging,
+	}
+}
+
+// Coordinates returns the coordinates of the artifact the POM describes
+func (p *POM) Coordinates() repo.Coordinates {
+	ext := p.Packaging
+	if ext == "" {
+		ext = repo.DefaultExtension
+	}
+	return repo.Coordinates{
+		GroupID:    p.GroupID,
+		ArtifactID: p.ArtifactID,
+		Extension:  ext,
+		Version:    p.Version,
 	}
 }
 
@@ -77,14 +123,34 @@ func URL(repoURL, groupID, artifactID, version string) (*url.URL, error) {
 	return url.Parse(fmt.Sprintf("%s%s-%s.pom", versionURL, artifactID, version))
 }
 
+// FetchOptions returns the options to fetch from the repository with its checksum policy, which is warn if not set
+// as with maven. Warnings are logged to the logger.
+func (r RepoPolicy) FetchOptions(logger repo.Logger) (repo.FetchOptions, error) {
+	policy, err := repo.ParseChecksumPolicy(r.ChecksumPolicy)
+	if err != nil {
+		return repo.FetchOptions{}, err
+	}
+	return repo.FetchOptions{ChecksumPolicy: policy, Logger: logger}, nil
+}
+
 func Get(repoURL, groupID, artifactID, version string, cl *http.Client) (p POM, err error) {
+	return GetContext(context.Background(), repoURL, groupID, artifactID, version, repo.FetchOptions{}, cl)
+}
+
+// GetWithOptions gets the POM of the artifact version, verifying it according to the options.
+func GetWithOptions(repoURL, groupID, artifactID, version string, opts repo.FetchOptions, cl *http.Client) (p POM, err error) {
+	return GetContext(context.Background(), repoURL, groupID, artifactID, version, opts, cl)
+}
+
+// GetContext gets the POM of the artifact version with the context, verifying it according to the options.
+func GetContext(ctx context.Context, repoURL, groupID, artifactID, version string, opts repo.FetchOptions, cl *http.Client) (p POM, err error) {
 	pomURL, err := URL(repoURL, groupID, artifactID, version)
 	if err != nil {
 		return
 	}
 
 	// Get the POM file
-	req, err := http.NewRequest("GET", pomURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", pomURL.String(), nil)
 	if err != nil {
 		err = fmt.Errorf("error forming request of %s: %v", pomURL, err)
 		return
@@ -97,6 +163,7 @@ func Get(repoURL, groupID, artifactID, version string, cl *http.Client) (p POM,
 		err = fmt.Errorf("error getting %s: %v", pomURL, err)
 		return
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		err = fmt.Errorf("http response %d downloading POM file", resp.StatusCode)
 		return
@@ -106,10 +173,9 @@ func Get(repoURL, groupID, artifactID, version string, cl *http.Client) (p POM,
 		err = fmt.Errorf("error reading body from %s: %v", pomURL, err)
 		return
 	}
-	resp.Body.Close()
 
-	ok, err := repo.SHA1(pomURL.String(), b, cl)
-	if !ok || err != nil {
+	err = opts.Verify(ctx, pomURL.String(), b, cl)
+	if err != nil {
 		err = fmt.Errorf("integrity check failed: %v", err)
 		return
 	}
@@ -134,6 +200,9 @@ func Load(path string) (POM, error) {
 	return p, nil
 }
 
+// Marshal returns the XML of the POM. Every element of the model is kept, so a POM that is unmarshaled and marshaled
+// again has the same content, but comments are dropped and elements are written in the order of the model rather than
+// as they were read.
 func (p *POM) Marshal() ([]byte, error) {
 	b := []byte(xml.Header)
 	pb, err := xml.MarshalIndent(p, "", "  ")
@@ -153,3 +222,64 @@ func (p *POM) Unmarshal(b []byte) error {
 	}
 	return nil
 }
+
+// Properties are the properties of a POM or settings profile, kept in the order they are declared.
+type Properties []Property
+
+// Property is a name and value of Properties
+type Property struct {
+	Name  string
+	Value string
+}
+
+// Get returns the value of the property with the name
+func (p Properties) Get(name string) (string, bool) {
+	for _, e := range p {
+		if e.Name == name {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of the property with the name, adding it if not already present
+func (p *Properties) Set(name, value string) {
+	for i, e := range *p {
+		if e.Name == name {
+			(*p)[i].Value = value
+			return
+		}
+	}
+	*p = append(*p, Property{Name: name, Value: value})
+}
+
+func (p Properties) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, prop := range p {
+		if err := e.EncodeElement(prop.Value, xml.StartElement{Name: xml.Name{Local: prop.Name}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (p *Properties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			var v string
+			if err := d.DecodeElement(&v, &t); err != nil {
+				return err
+			}
+			*p = append(*p, Property{Name: t.Name.Local, Value: v})
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
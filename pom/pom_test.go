@@ -1,7 +1,16 @@
 package pom
 
 import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMarsahl(t *testing.T) {
@@ -12,6 +21,205 @@ func TestMarsahl(t *testing.T) {
 	}
 }
 
+func TestRepoPolicy_FetchOptions(t *testing.T) {
+	var tests = []struct {
+		checksumPolicy string
+		policy         repo.ChecksumPolicy
+	}{
+		{"", repo.ChecksumPolicyWarn},
+		{"fail", repo.ChecksumPolicyFail},
+		{"warn", repo.ChecksumPolicyWarn},
+		{"ignore", repo.ChecksumPolicyIgnore},
+	}
+	for _, test := range tests {
+		opts, err := RepoPolicy{ChecksumPolicy: test.checksumPolicy}.FetchOptions(nil)
+		if err != nil {
+			t.Errorf("error getting fetch options for %q: %v", test.checksumPolicy, err)
+		}
+		if opts.ChecksumPolicy != test.policy {
+			t.Errorf("checksum policy %q; expected %s ; got %s", test.checksumPolicy, test.policy, opts.ChecksumPolicy)
+		}
+	}
+	if _, err := (RepoPolicy{ChecksumPolicy: "never"}).FetchOptions(nil); err == nil {
+		t.Error("expected error for invalid checksum policy")
+	}
+}
+
+func TestProperties(t *testing.T) {
+	type profile struct {
+		XMLName    xml.Name   `xml:"profile"`
+		Properties Properties `xml:"properties,omitempty"`
+	}
+	in := `<profile><properties><b.version>2.0</b.version><a.version>1.0</a.version></properties></profile>`
+	var p profile
+	if err := xml.Unmarshal([]byte(in), &p); err != nil {
+		t.Fatalf("error unmarshaling properties: %v", err)
+	}
+	v, ok := p.Properties.Get("a.version")
+	assert.True(t, ok)
+	assert.Equal(t, "1.0", v)
+	p.Properties.Set("a.version", "1.1")
+	p.Properties.Set("c.version", "3.0")
+	b, err := xml.Marshal(p)
+	if err != nil {
+		t.Fatalf("error marshaling properties: %v", err)
+	}
+	assert.Equal(t, `<profile><properties><b.version>2.0</b.version><a.version>1.1</a.version><c.version>3.0</c.version></properties></profile>`, string(b))
+
+	b, err = xml.Marshal(profile{})
+	if err != nil {
+		t.Fatalf("error marshaling properties: %v", err)
+	}
+	assert.Equal(t, `<profile></profile>`, string(b), "empty properties should be omitted")
+}
+
+func TestLoad_RoundTrip(t *testing.T) {
+	for _, f := range []string{"testdata/guava-parent.pom", "testdata/spring-boot-starter-parent.pom", "testdata/edge-cases.pom"} {
+		p, err := Load(f)
+		if err != nil {
+			t.Fatalf("error loading %s: %v", f, err)
+		}
+		b, err := p.Marshal()
+		if err != nil {
+			t.Fatalf("error marshaling %s: %v", f, err)
+		}
+		orig, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, canonical(t, orig), canonical(t, b), "%s not round tripped", f)
+
+		// marshaling is stable
+		var p2 POM
+		if err := p2.Unmarshal(b); err != nil {
+			t.Fatalf("error unmarshaling %s: %v", f, err)
+		}
+		b2, err := p2.Marshal()
+		if err != nil {
+			t.Fatalf("error marshaling %s: %v", f, err)
+		}
+		assert.Equal(t, string(b), string(b2))
+	}
+}
+
+func TestPOM_Model(t *testing.T) {
+	p, err := Load("testdata/spring-boot-starter-parent.pom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, p.Parent) {
+		assert.Equal(t, "spring-boot-dependencies", p.Parent.ArtifactID)
+		assert.Nil(t, p.Parent.RelativePath)
+	}
+	assert.Equal(t, "", p.GroupID)
+	v, _ := p.Properties.Get("java.version")
+	assert.Equal(t, "1.8", v)
+	plugins := *p.Build.PluginManagement.Plugins
+	assert.Equal(t, "kotlin-maven-plugin", plugins[0].ArtifactID)
+	assert.Contains(t, plugins[2].Configuration.InnerXML, "<mainClass>${start-class}</mainClass>")
+
+	p, err = Load("testdata/edge-cases.pom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, p.Parent) && assert.NotNil(t, p.Parent.RelativePath, "empty relativePath should be kept") {
+		assert.Equal(t, "", *p.Parent.RelativePath)
+	}
+	assert.Equal(t, "false", p.ChildProjectURLInheritAppendPath)
+	assert.True(t, (*p.DependencyManagement.Dependencies)[0].IsOptional())
+	d := (*p.Dependencies)[0]
+	assert.Equal(t, "${tools.optional}", d.Optional)
+	assert.False(t, d.IsOptional())
+	assert.Equal(t, []Exclusion{{GroupID: "*", ArtifactID: "*"}}, *d.Exclusions)
+	r := (*p.PluginRepositories)[0]
+	assert.Nil(t, r.Releases)
+	assert.False(t, r.Snapshots.IsEnabled())
+	assert.True(t, RepoPolicy{}.IsEnabled(), "policy should be enabled unless set otherwise")
+	plugins = *p.Build.PluginManagement.Plugins
+	assert.Equal(t, "true", plugins[0].Extensions)
+	assert.Contains(t, plugins[1].Configuration.InnerXML, "<mainClass>${start-class}</mainClass>")
+
+	p, err = Load("testdata/guava-parent.pom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm := *p.DependencyManagement.Dependencies
+	assert.Equal(t, "sources", dm[2].Classifier)
+	assert.False(t, dm[4].IsOptional())
+	assert.Equal(t, "kevinb9n", (*p.Developers)[0].ID)
+	assert.Equal(t, "Kevin Bourrillion", (*p.Developers)[0].Name)
+	assert.Equal(t, []string{"guava", "guava-bom", "guava-gwt", "guava-testlib", "guava-tests"}, *p.Modules)
+	assert.Equal(t, "src", p.Build.SourceDirectory)
+	assert.Equal(t, "1.8", (*p.Profiles)[1].Activation.JDK)
+}
+
+func TestNew_Marshal(t *testing.T) {
+	p := New("grpID", "artID", "1.0.1", "jar")
+	b, err := p.Marshal()
+	if err != nil {
+		t.Fatalf("error mashaling pom: %v", err)
+	}
+	assert.Equal(t, xml.Header+`<project xmlns="http://maven.apache.org/POM/4.0.0" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://maven.apache.org/POM/4.0.0 https://maven.apache.org/xsd/maven-4.0.0.xsd">
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>grpID</groupId>
+  <artifactId>artID</artifactId>
+  <version>1.0.1</version>
+  <packaging>jar</packaging>
+</project>`, string(b))
+}
+
+// canonical returns the XML as an indented tree of its elements, attributes and text, ignoring comments, namespace
+// declarations and the order of differently named sibling elements. Marshaling a POM drops its comments and writes its
+// elements in the order of the model, so a round trip is only semantic: the canonical forms match, not the bytes.
+func canonical(t *testing.T, b []byte) string {
+	type node struct {
+		name     string
+		attrs    []string
+		text     string
+		children []*node
+	}
+	var render func(n *node, indent string) string
+	render = func(n *node, indent string) string {
+		sort.Strings(n.attrs)
+		sort.SliceStable(n.children, func(i, j int) bool { return n.children[i].name < n.children[j].name })
+		s := fmt.Sprintf("%s%s %v %q\n", indent, n.name, n.attrs, strings.TrimSpace(n.text))
+		for _, c := range n.children {
+			s += render(c, indent+"  ")
+		}
+		return s
+	}
+	root := &node{}
+	stack := []*node{root}
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		top := stack[len(stack)-1]
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: tok.Name.Local}
+			for _, a := range tok.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				n.attrs = append(n.attrs, a.Name.Local+"="+a.Value)
+			}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			top.text += string(tok)
+		}
+	}
+	if len(stack) != 1 {
+		t.Fatal("unbalanced XML")
+	}
+	return render(root, "")
+}
+
 //func TestPOM(t *testing.T) {
 //	md, err := metadata.Get("http://central.maven.org/maven2", "log4j", "log4j")
 //	if err != nil {
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Authenticator adds credentials to a request made to a repository.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// BasicAuth authenticates with a username and password.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerToken authenticates with a token in the Authorization header.
+type BearerToken struct {
+	Token string
+}
+
+func (a BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// HeaderToken authenticates with a token in an arbitrary header, such as X-JFrog-Art-Api.
+type HeaderToken struct {
+	Header string
+	Token  string
+}
+
+func (a HeaderToken) Authenticate(req *http.Request) error {
+	if a.Header == "" {
+		return fmt.Errorf("no header given for token authentication")
+	}
+	req.Header.Set(a.Header, a.Token)
+	return nil
+}
+
+// Anonymous sends no credentials.
+type Anonymous struct{}
+
+func (Anonymous) Authenticate(req *http.Request) error {
+	return nil
+}
+
+// AuthTransport is an http.RoundTripper that authenticates each request before sending it with the base transport.
+type AuthTransport struct {
+	Authenticator Authenticator
+	// Host, if set, restricts authentication to requests to the host so that credentials are not sent to another
+	// host the repository redirects to.
+	Host string
+	// Base is the transport used to send requests, http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+func (t *AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Authenticator == nil || (t.Host != "" && !strings.EqualFold(req.URL.Host, t.Host)) {
+		return base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request it is given
+	areq := req.Clone(req.Context())
+	if err := t.Authenticator.Authenticate(areq); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("error authenticating request to %s: %v", req.URL, err)
+	}
+	return base.RoundTrip(areq)
+}
+
+// NewAuthClient returns a copy of the client that authenticates every request to the host of the repository.
+// http.DefaultClient is copied if cl is nil.
+func NewAuthClient(repoURL string, auth Authenticator, cl *http.Client) (*http.Client, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("repository URL not valid: %v", err)
+	}
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+	c := *cl
+	c.Transport = &AuthTransport{
+		Authenticator: auth,
+		Host:          u.Host,
+		Base:          cl.Transport,
+	}
+	return &c, nil
+}
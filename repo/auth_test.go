@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticators(t *testing.T) {
+	var tests = []struct {
+		name   string
+		auth   Authenticator
+		header string
+		value  string
+	}{
+		{"basic", BasicAuth{Username: "user", Password: "pass"}, "Authorization", "Basic dXNlcjpwYXNz"},
+		{"bearer", BearerToken{Token: "abc123"}, "Authorization", "Bearer abc123"},
+		{"header", HeaderToken{Header: "X-JFrog-Art-Api", Token: "abc123"}, "X-JFrog-Art-Api", "abc123"},
+		{"anonymous", Anonymous{}, "Authorization", ""},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "https://repo.example.com/", nil)
+		err := test.auth.Authenticate(req)
+		if err != nil {
+			t.Errorf("%s: error authenticating: %v", test.name, err)
+		}
+		assert.Equal(t, test.value, req.Header.Get(test.header), test.name)
+	}
+	req, _ := http.NewRequest("GET", "https://repo.example.com/", nil)
+	if err := (HeaderToken{Token: "abc123"}).Authenticate(req); err == nil {
+		t.Error("expected error for header token without a header")
+	}
+}
+
+func TestNewAuthClient(t *testing.T) {
+	var other string
+	o := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = r.Header.Get("Authorization")
+		w.Write([]byte(mavenMetaDataSHA1))
+	}))
+	defer o.Close()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case mavenMetadataURL + ".sha1":
+			http.Redirect(w, r, o.URL+r.URL.Path, http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	_, _, err := StrongestChecksum(context.Background(), s.URL+mavenMetadataURL, nil)
+	assert.NotNil(t, err, "unauthenticated request should fail")
+
+	cl, err := NewAuthClient(s.URL, BearerToken{Token: "abc123"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alg, c, err := StrongestChecksum(context.Background(), s.URL+mavenMetadataURL, cl)
+	if err != nil {
+		t.Fatalf("error getting checksum with authenticated client: %v", err)
+	}
+	assert.Equal(t, ChecksumSHA1, alg)
+	assert.Equal(t, mavenMetaDataSHA1, c)
+	assert.Equal(t, "", other, "credentials sent to the host redirected to")
+}
@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ChecksumAlgorithm is a checksum algorithm, named by the suffix of the checksum files published with it.
+type ChecksumAlgorithm string
+
+const (
+	ChecksumMD5    ChecksumAlgorithm = "md5"
+	ChecksumSHA1   ChecksumAlgorithm = "sha1"
+	ChecksumSHA256 ChecksumAlgorithm = "sha256"
+	ChecksumSHA512 ChecksumAlgorithm = "sha512"
+)
+
+// ChecksumAlgorithms are the supported checksum algorithms, strongest first.
+var ChecksumAlgorithms = []ChecksumAlgorithm{ChecksumSHA512, ChecksumSHA256, ChecksumSHA1, ChecksumMD5}
+
+// DefaultChecksums are the checksums published alongside deployed files unless configured otherwise.
+var DefaultChecksums = []ChecksumAlgorithm{ChecksumSHA1, ChecksumMD5}
+
+// Hash returns a new hash for the algorithm, or nil if the algorithm is not supported.
+func (a ChecksumAlgorithm) Hash() hash.Hash {
+	switch a {
+	case ChecksumMD5:
+		return md5.New()
+	case ChecksumSHA1:
+		return sha1.New()
+	case ChecksumSHA256:
+		return sha256.New()
+	case ChecksumSHA512:
+		return sha512.New()
+	}
+	return nil
+}
+
+// ChecksumNotFound is returned when no checksum file is published alongside a file.
+type ChecksumNotFound struct {
+	ErrorString string
+}
+
+func (e ChecksumNotFound) Error() string {
+	return e.ErrorString
+}
+
+// ChecksumMismatch is returned when a file does not match its published checksum.
+type ChecksumMismatch struct {
+	ErrorString string
+}
+
+func (e ChecksumMismatch) Error() string {
+	return e.ErrorString
+}
+
+// ChecksumPolicy is how a fetched file is handled when it does not match, or cannot be verified against, its
+// published checksum. The policies are those of maven's checksumPolicy.
+type ChecksumPolicy string
+
+const (
+	ChecksumPolicyFail   ChecksumPolicy = "fail"
+	ChecksumPolicyWarn   ChecksumPolicy = "warn"
+	ChecksumPolicyIgnore ChecksumPolicy = "ignore"
+)
+
+// ParseChecksumPolicy parses a maven checksumPolicy value. As with maven an empty value is the warn policy.
+func ParseChecksumPolicy(s string) (ChecksumPolicy, error) {
+	p := ChecksumPolicy(strings.ToLower(strings.TrimSpace(s)))
+	switch p {
+	case "":
+		return ChecksumPolicyWarn, nil
+	case ChecksumPolicyFail, ChecksumPolicyWarn, ChecksumPolicyIgnore:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid checksum policy %q", s)
+}
+
+// Logger receives warnings, such as those of the warn checksum policy. *log.Logger satisfies the interface.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// FetchOptions control how files fetched from a repository are verified.
+// The zero value fails on any checksum problem and logs to the standard logger.
+type FetchOptions struct {
+	ChecksumPolicy ChecksumPolicy
+	Logger         Logger
+}
+
+// Check applies the checksum policy to the error verifying the file at the URL. The warn policy logs the error and
+// returns nil, the ignore policy returns nil and the fail policy returns the error.
+func (o FetchOptions) Check(furl string, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch o.ChecksumPolicy {
+	case ChecksumPolicyIgnore:
+		return nil
+	case ChecksumPolicyWarn:
+		l := o.Logger
+		if l == nil {
+			l = stdLogger{}
+		}
+		l.Printf("[WARNING] could not verify checksum of %s: %v", furl, err)
+		return nil
+	}
+	return err
+}
+
+// Verify verifies the bytes against the strongest checksum published alongside the file at the URL, applying the
+// checksum policy to any failure. No checksum is fetched under the ignore policy.
+func (o FetchOptions) Verify(ctx context.Context, furl string, b []byte, cl *http.Client) error {
+	if o.ChecksumPolicy == ChecksumPolicyIgnore {
+		return nil
+	}
+	alg, expected, err := StrongestChecksum(ctx, furl, cl)
+	if err == nil {
+		_, err = verify(furl, alg, expected, b)
+	}
+	return o.Check(furl, err)
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultExtension is the extension of an artifact when one is not specified
+const DefaultExtension = "jar"
+
+var (
+	idPattern      = regexp.MustCompile(`^[A-Za-z0-9_\-.]+$`)
+	versionInvalid = regexp.MustCompile(`[\s/\\:]`)
+)
+
+// Coordinates identify an artifact file in a repository
+type Coordinates struct {
+	GroupID    string
+	ArtifactID string
+	Extension  string
+	Classifier string
+	Version    string
+}
+
+// NewCoordinates parses coordinates in the form groupId:artifactId[:extension[:classifier]]:version
+func NewCoordinates(s string) (Coordinates, error) {
+	var c Coordinates
+	p := strings.Split(s, ":")
+	switch len(p) {
+	case 3:
+		c = Coordinates{GroupID: p[0], ArtifactID: p[1], Version: p[2]}
+	case 4:
+		c = Coordinates{GroupID: p[0], ArtifactID: p[1], Extension: p[2], Version: p[3]}
+	case 5:
+		c = Coordinates{GroupID: p[0], ArtifactID: p[1], Extension: p[2], Classifier: p[3], Version: p[4]}
+	default:
+		return c, fmt.Errorf("invalid coordinates %s: expected groupId:artifactId[:extension[:classifier]]:version", s)
+	}
+	if c.Extension == "" {
+		c.Extension = DefaultExtension
+	}
+	if err := c.Validate(); err != nil {
+		return c, fmt.Errorf("invalid coordinates %s: %v", s, err)
+	}
+	return c, nil
+}
+
+// Validate checks each segment of the coordinates can be used in a repository path
+func (c Coordinates) Validate() error {
+	for _, s := range []struct {
+		name     string
+		value    string
+		optional bool
+	}{
+		{"groupId", c.GroupID, false},
+		{"artifactId", c.ArtifactID, false},
+		{"extension", c.Extension, true},
+		{"classifier", c.Classifier, true},
+	} {
+		if s.value == "" {
+			if s.optional {
+				continue
+			}
+			return fmt.Errorf("%s is empty", s.name)
+		}
+		if !idPattern.MatchString(s.value) {
+			return fmt.Errorf("%s %q contains invalid characters", s.name, s.value)
+		}
+		if strings.Contains(s.value, "..") {
+			return fmt.Errorf("%s %q contains '..'", s.name, s.value)
+		}
+	}
+	if c.Version == "" {
+		return errors.New("version is empty")
+	}
+	if versionInvalid.MatchString(c.Version) || c.Version == "." || c.Version == ".." {
+		return fmt.Errorf("version %q contains invalid characters", c.Version)
+	}
+	return nil
+}
+
+// String returns the coordinates in the form groupId:artifactId:extension[:classifier]:version
+func (c Coordinates) String() string {
+	p := []string{c.GroupID, c.ArtifactID, c.extension()}
+	if c.Classifier != "" {
+		p = append(p, c.Classifier)
+	}
+	return strings.Join(append(p, c.Version), ":")
+}
+
+func (c Coordinates) extension() string {
+	if c.Extension == "" {
+		return DefaultExtension
+	}
+	return c.Extension
+}
+
+// GroupPath returns the repository path of the group. eg org/example
+func (c Coordinates) GroupPath() string {
+	return strings.Join(strings.Split(c.GroupID, "."), "/")
+}
+
+// ArtifactPath returns the repository path of the artifact which holds the artifact level metadata.
+// eg org/example/artifact
+func (c Coordinates) ArtifactPath() string {
+	return c.GroupPath() + "/" + c.ArtifactID
+}
+
+// VersionPath returns the repository path of the version. eg org/example/artifact/1.0
+func (c Coordinates) VersionPath() string {
+	return c.ArtifactPath() + "/" + c.Version
+}
+
+// FileName returns the name of the file. eg artifact-1.0-sources.jar
+func (c Coordinates) FileName() string {
+	return c.FileNameForVersion(c.Version)
+}
+
+// FileNameForVersion returns the name of the file for a different file version, such as the timestamped version of
+// a SNAPSHOT.
+func (c Coordinates) FileNameForVersion(fileVersion string) string {
+	if c.Classifier != "" {
+		return fmt.Sprintf("%s-%s-%s.%s", c.ArtifactID, fileVersion, c.Classifier, c.extension())
+	}
+	return fmt.Sprintf("%s-%s.%s", c.ArtifactID, fileVersion, c.extension())
+}
+
+// Path returns the repository path of the file. eg org/example/artifact/1.0/artifact-1.0-sources.jar
+func (c Coordinates) Path() string {
+	return c.VersionPath() + "/" + c.FileName()
+}
+
+// VersionURL returns the URL of the version directory in the repository, with a trailing slash
+func (c Coordinates) VersionURL(repoURL string) string {
+	return fmt.Sprintf("%s/%s/", strings.TrimRight(repoURL, "/"), c.VersionPath())
+}
+
+// URL returns the URL of the file in the repository
+func (c Coordinates) URL(repoURL string) string {
+	return c.VersionURL(repoURL) + c.FileName()
+}
+
+// POM returns the coordinates of the POM of the artifact
+func (c Coordinates) POM() Coordinates {
+	return Coordinates{GroupID: c.GroupID, ArtifactID: c.ArtifactID, Extension: "pom", Version: c.Version}
+}
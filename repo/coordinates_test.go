@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCoordinates(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected Coordinates
+		str      string
+		path     string
+	}{
+		{
+			"org.example:artifact:1.0",
+			Coordinates{GroupID: "org.example", ArtifactID: "artifact", Extension: "jar", Version: "1.0"},
+			"org.example:artifact:jar:1.0",
+			"org/example/artifact/1.0/artifact-1.0.jar",
+		},
+		{
+			"org.example:artifact:pom:1.0",
+			Coordinates{GroupID: "org.example", ArtifactID: "artifact", Extension: "pom", Version: "1.0"},
+			"org.example:artifact:pom:1.0",
+			"org/example/artifact/1.0/artifact-1.0.pom",
+		},
+		{
+			"org.example:artifact:jar:sources:1.0-SNAPSHOT",
+			Coordinates{GroupID: "org.example", ArtifactID: "artifact", Extension: "jar", Classifier: "sources", Version: "1.0-SNAPSHOT"},
+			"org.example:artifact:jar:sources:1.0-SNAPSHOT",
+			"org/example/artifact/1.0-SNAPSHOT/artifact-1.0-SNAPSHOT-sources.jar",
+		},
+		{
+			"org.example:artifact::linux-amd64:1.0",
+			Coordinates{GroupID: "org.example", ArtifactID: "artifact", Extension: "jar", Classifier: "linux-amd64", Version: "1.0"},
+			"org.example:artifact:jar:linux-amd64:1.0",
+			"org/example/artifact/1.0/artifact-1.0-linux-amd64.jar",
+		},
+	}
+	for _, test := range tests {
+		c, err := NewCoordinates(test.in)
+		if err != nil {
+			t.Errorf("error parsing %s: %v", test.in, err)
+			continue
+		}
+		assert.Equal(t, test.expected, c)
+		assert.Equal(t, test.str, c.String())
+		assert.Equal(t, test.path, c.Path())
+		assert.Equal(t, "https://repourl/"+test.path, c.URL("https://repourl/"))
+	}
+}
+
+func TestNewCoordinates_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"org.example:artifact",
+		"org.example:artifact:jar:sources:extra:1.0",
+		":artifact:1.0",
+		"org.example::1.0",
+		"org.example:artifact:",
+		"org/example:artifact:1.0",
+		"org.example:art ifact:1.0",
+		"org.example:artifact:1.0/../..",
+		"org.example:artifact:..",
+		"..:artifact:1.0",
+		"org.example:artifact:j/ar:1.0",
+	}
+	for _, test := range tests {
+		_, err := NewCoordinates(test)
+		assert.NotNil(t, err, "coordinates %q should be invalid", test)
+	}
+}
+
+func TestCoordinates_FileNameForVersion(t *testing.T) {
+	c, _ := NewCoordinates("org.example:artifact:jar:sources:1.0-SNAPSHOT")
+	assert.Equal(t, "artifact-1.0-20201012.101112-3-sources.jar", c.FileNameForVersion("1.0-20201012.101112-3"))
+	assert.Equal(t, "org/example/artifact/1.0-SNAPSHOT/artifact-1.0-SNAPSHOT.pom", c.POM().Path())
+}
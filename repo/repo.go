@@ -1,10 +1,14 @@
+// Package repo provides access to the files of a maven repository over HTTP.
+//
+// Requests are sent once with the *http.Client given, or http.DefaultClient if nil, here and in the getfile,
+// deployfile, metadata and pom packages. Retrying requests that fail with a transient error is opt in, by passing a
+// client returned by NewRetryClient.
 package repo
 
 import (
 	"bufio"
-	"crypto/sha1"
+	"context"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,46 +16,112 @@ import (
 )
 
 func ParseCoordinates(repoURL, groupID, artifactID, packaging, version string) (groupURL, versionURL, filename string) {
-	groupPath := strings.Join(strings.Split(groupID, "."), "/")
+	c := Coordinates{GroupID: groupID, ArtifactID: artifactID, Extension: packaging, Version: version}
 	filename = fmt.Sprintf("%s-%s.%s", artifactID, version, packaging)
-	groupURL = fmt.Sprintf("%s/%s/", strings.TrimRight(repoURL, "/"), groupPath)
-	versionURL = fmt.Sprintf("%s/%s/%s/%s/", strings.TrimRight(repoURL, "/"), groupPath, artifactID, version)
+	groupURL = fmt.Sprintf("%s/%s/", strings.TrimRight(repoURL, "/"), c.GroupPath())
+	versionURL = c.VersionURL(repoURL)
 	return
 }
 
+// SHA1 verifies the bytes against the sha1 checksum file published alongside the file at the URL
 func SHA1(furl string, b []byte, cl *http.Client) (bool, error) {
-	sha1url := furl + ".sha1"
-	req, err := http.NewRequest("GET", sha1url, nil)
+	return SHA1Context(context.Background(), furl, b, cl)
+}
+
+// SHA1Context verifies the bytes against the sha1 checksum file as SHA1 does, fetching it with the context.
+func SHA1Context(ctx context.Context, furl string, b []byte, cl *http.Client) (bool, error) {
+	expected, err := RemoteChecksum(ctx, furl, ChecksumSHA1, cl)
+	if err != nil {
+		return false, err
+	}
+	return verify(furl, ChecksumSHA1, expected, b)
+}
+
+// Verify verifies the bytes against the strongest checksum published alongside the file at the URL.
+// A ChecksumNotFound error is returned if no checksum is published.
+func Verify(furl string, b []byte, cl *http.Client) (bool, error) {
+	return VerifyContext(context.Background(), furl, b, cl)
+}
+
+// VerifyContext verifies the bytes against the strongest checksum as Verify does, fetching it with the context.
+func VerifyContext(ctx context.Context, furl string, b []byte, cl *http.Client) (bool, error) {
+	alg, expected, err := StrongestChecksum(ctx, furl, cl)
+	if err != nil {
+		return false, err
+	}
+	return verify(furl, alg, expected, b)
+}
+
+func verify(furl string, alg ChecksumAlgorithm, expected string, b []byte) (bool, error) {
+	hash := alg.Hash()
+	hash.Write(b)
+	h := hex.EncodeToString(hash.Sum(nil))
+	if h != expected {
+		return false, ChecksumMismatch{
+			ErrorString: fmt.Sprintf("checksum (%s.%s) does not match. expected: %s got: %s", furl, alg, expected, h),
+		}
+	}
+	return true, nil
+}
+
+// StrongestChecksum gets the strongest of the checksums published alongside the file at the URL, trying each of
+// ChecksumAlgorithms in turn. A ChecksumNotFound error is returned if none are published.
+func StrongestChecksum(ctx context.Context, furl string, cl *http.Client) (ChecksumAlgorithm, string, error) {
+	for _, alg := range ChecksumAlgorithms {
+		c, err := RemoteChecksum(ctx, furl, alg, cl)
+		if err != nil {
+			if _, ok := err.(ChecksumNotFound); ok {
+				continue
+			}
+			return alg, "", err
+		}
+		return alg, c, nil
+	}
+	return "", "", ChecksumNotFound{
+		ErrorString: fmt.Sprintf("no checksum published for %s", furl),
+	}
+}
+
+// RemoteChecksum gets the checksum published alongside the file at the URL for the algorithm.
+// The checksum is returned as lower case hex. A ChecksumNotFound error is returned if there is no checksum file.
+func RemoteChecksum(ctx context.Context, furl string, algorithm ChecksumAlgorithm, cl *http.Client) (string, error) {
+	h := algorithm.Hash()
+	if h == nil {
+		return "", fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	curl := furl + "." + string(algorithm)
+	req, err := http.NewRequestWithContext(ctx, "GET", curl, nil)
 	if err != nil {
-		return false, fmt.Errorf("could not form request to check sha1 %s: %v", furl, err)
+		return "", fmt.Errorf("could not form request to check %s %s: %v", algorithm, furl, err)
 	}
 	if cl == nil {
 		cl = http.DefaultClient
 	}
 	resp, err := cl.Do(req)
 	if err != nil {
-		return false, fmt.Errorf("error fetching sha1 file: %s: %v", sha1url, err)
+		return "", fmt.Errorf("error fetching %s file: %s: %v", algorithm, curl, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ChecksumNotFound{
+			ErrorString: fmt.Sprintf("http response %d downloading %s file (%s)", resp.StatusCode, algorithm, curl),
+		}
 	}
 	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("http response %d downloading sha1 file (%s)", resp.StatusCode, sha1url)
+		return "", fmt.Errorf("http response %d downloading %s file (%s)", resp.StatusCode, algorithm, curl)
 	}
 	r := bufio.NewReader(resp.Body)
-	defer resp.Body.Close()
-	mdsha1, err := r.ReadString('\n')
+	c, err := r.ReadString('\n')
 	if err != nil && err != io.EOF {
-		return false, fmt.Errorf("error reading content of sha1 file %s: %v", sha1url, err)
+		return "", fmt.Errorf("error reading content of %s file %s: %v", algorithm, curl, err)
 	}
-	if mdsha1 == "" {
-		return false, errors.New("sha1 returned is empty")
+	if strings.TrimSpace(c) == "" {
+		return "", fmt.Errorf("%s returned is empty", algorithm)
 	}
-	mdsha1 = strings.ToLower(strings.TrimSpace(strings.Fields(mdsha1)[0]))
-
-	// Check the md5 of the metadata
-	hash := sha1.New()
-	hash.Write(b)
-	h := hex.EncodeToString(hash.Sum(nil))
-	if strings.ToLower(h) != mdsha1 {
-		return false, fmt.Errorf("checksum (%s) does not match. expected: %s got: %s", sha1url, mdsha1, h)
+	// the file may hold the checksum followed by the file name
+	c = strings.ToLower(strings.Fields(c)[0])
+	if _, err := hex.DecodeString(c); err != nil || len(c) != hex.EncodedLen(h.Size()) {
+		return "", fmt.Errorf("%s file %s does not hold a valid checksum", algorithm, curl)
 	}
-	return true, nil
+	return c, nil
 }
@@ -1,8 +1,14 @@
 package repo
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -44,6 +50,125 @@ func TestSHA1(t *testing.T) {
 	}
 }
 
+func TestVerify(t *testing.T) {
+	b := []byte(mavenMetaData)
+	md5sum := md5.Sum(b)
+	sha256sum := sha256.Sum256(b)
+	var tests = []struct {
+		name      string
+		checksums map[string]string
+		alg       ChecksumAlgorithm
+		ok        bool
+	}{
+		{"sha1 only", map[string]string{"sha1": mavenMetaDataSHA1}, ChecksumSHA1, true},
+		{"md5 only", map[string]string{"md5": hex.EncodeToString(md5sum[:])}, ChecksumMD5, true},
+		{"strongest used", map[string]string{
+			"sha256": hex.EncodeToString(sha256sum[:]) + "  maven-metadata.xml",
+			"sha1":   "0000000000000000000000000000000000000000",
+		}, ChecksumSHA256, true},
+		{"strongest mismatch", map[string]string{
+			"sha256": "0000000000000000000000000000000000000000000000000000000000000000",
+			"sha1":   mavenMetaDataSHA1,
+		}, ChecksumSHA256, false},
+		{"invalid checksum", map[string]string{"sha512": "invalid", "sha1": mavenMetaDataSHA1}, ChecksumSHA512, false},
+	}
+	for _, test := range tests {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for alg, c := range test.checksums {
+				if r.URL.Path == mavenMetadataURL+"."+alg {
+					w.Write([]byte(c))
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		alg, _, _ := StrongestChecksum(context.Background(), s.URL+mavenMetadataURL, nil)
+		if alg != test.alg {
+			t.Errorf("%s: expected %s checksum to be used, got %s", test.name, test.alg, alg)
+		}
+		ok, err := Verify(s.URL+mavenMetadataURL, b, nil)
+		if ok != test.ok {
+			t.Errorf("%s: expected verification result %t, got %t (%v)", test.name, test.ok, ok, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("%s: expected an error when verification fails", test.name)
+		}
+		s.Close()
+	}
+}
+
+func TestVerify_NoChecksum(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+	ok, err := Verify(s.URL+mavenMetadataURL, []byte(mavenMetaData), nil)
+	if ok {
+		t.Error("verification passed with no published checksum")
+	}
+	if _, isNotFound := err.(ChecksumNotFound); !isNotFound {
+		t.Errorf("expected ChecksumNotFound error, got: %v", err)
+	}
+}
+
+func TestParseChecksumPolicy(t *testing.T) {
+	var tests = []struct {
+		s      string
+		policy ChecksumPolicy
+	}{
+		{"", ChecksumPolicyWarn},
+		{"fail", ChecksumPolicyFail},
+		{"warn", ChecksumPolicyWarn},
+		{"IGNORE", ChecksumPolicyIgnore},
+	}
+	for _, test := range tests {
+		p, err := ParseChecksumPolicy(test.s)
+		if err != nil {
+			t.Errorf("error parsing checksum policy %q: %v", test.s, err)
+		}
+		if p != test.policy {
+			t.Errorf("checksum policy %q; expected %s ; got %s", test.s, test.policy, p)
+		}
+	}
+	if _, err := ParseChecksumPolicy("strict"); err == nil {
+		t.Error("expected error parsing invalid checksum policy")
+	}
+}
+
+func TestFetchOptions_Verify(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+	b := []byte(mavenMetaData)
+	var tests = []struct {
+		policy ChecksumPolicy
+		url    string
+		b      []byte
+		err    bool
+		warned bool
+	}{
+		{ChecksumPolicyFail, mavenMetadataURL, b, false, false},
+		{ChecksumPolicyFail, mavenMetadataURL, b[1:], true, false},
+		{ChecksumPolicyFail, "/missing.xml", b, true, false},
+		{ChecksumPolicyWarn, mavenMetadataURL, b, false, false},
+		{ChecksumPolicyWarn, mavenMetadataURL, b[1:], false, true},
+		{ChecksumPolicyWarn, "/missing.xml", b, false, true},
+		{ChecksumPolicyIgnore, mavenMetadataURL, b[1:], false, false},
+		{ChecksumPolicyIgnore, "/missing.xml", b, false, false},
+		{"", "/missing.xml", b, true, false},
+	}
+	for _, test := range tests {
+		buf := new(strings.Builder)
+		opts := FetchOptions{ChecksumPolicy: test.policy, Logger: log.New(buf, "", 0)}
+		err := opts.Verify(context.Background(), s.URL+test.url, test.b, nil)
+		if (err != nil) != test.err {
+			t.Errorf("policy %q %s: expected error %t, got: %v", test.policy, test.url, test.err, err)
+		}
+		if (buf.Len() > 0) != test.warned {
+			t.Errorf("policy %q %s: expected warning %t, got: %q", test.policy, test.url, test.warned, buf.String())
+		}
+	}
+}
+
 const (
 	mavenMetadataURL = "/log4j/log4j/maven-metadata.xml"
 	mavenMetaData    = `<?xml version="1.0" encoding="UTF-8"?>
@@ -88,6 +213,8 @@ func testServer() *httptest.Server {
 				w.Write([]byte(mavenMetaDataSHA1))
 				return
 			}
+			w.WriteHeader(http.StatusNotFound)
+			return
 		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
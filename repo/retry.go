@@ -0,0 +1,171 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy is how requests that fail with a transient error are retried
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts of a request, including the first
+	Attempts int
+	// Backoff is the maximum delay before the first retry, doubling for each further retry. The actual delay is a
+	// random duration up to the maximum.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy makes up to three attempts of a request
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// delay returns how long to wait before the retry following the attempt that got the response.
+// The delay given by a Retry-After header of the response is used if there is one, capped by MaxBackoff.
+func (p RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				d = p.MaxBackoff
+			}
+			return d
+		}
+	}
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// retryAfter parses the value of a Retry-After header, either a number of seconds or a HTTP date
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// RetryTransport is an http.RoundTripper that retries requests failing with a transient error according to the
+// policy. Only idempotent requests are retried, and only if their body can be sent again with GetBody.
+//
+// Retrying is opt in: the functions of this module taking a *http.Client only retry if given a client using a
+// RetryTransport, such as one returned by NewRetryClient.
+type RetryTransport struct {
+	Policy RetryPolicy
+	// Base is the transport used to send requests, http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !idempotent(req.Method) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return base.RoundTrip(req)
+	}
+	r := req
+	for attempt := 1; ; attempt++ {
+		resp, err := base.RoundTrip(r)
+		if attempt >= t.Policy.Attempts || !transient(req.Context(), resp, err) {
+			return resp, err
+		}
+		d := t.Policy.delay(attempt, resp)
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		// a RoundTripper must not modify the request it is given
+		r = req.Clone(req.Context())
+		if req.GetBody != nil {
+			r.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// idempotent returns if requests with the method can safely be repeated
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// transient returns if the failure of a request may succeed if retried. Errors are only transient if they are a
+// timeout or the connection being reset or closed part way through the response, and no failure is transient once
+// the context of the request is done.
+func transient(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// NewRetryClient returns a copy of the client that retries requests according to the policy.
+// http.DefaultClient is copied if cl is nil.
+func NewRetryClient(p RetryPolicy, cl *http.Client) *http.Client {
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+	c := *cl
+	c.Transport = &RetryTransport{
+		Policy: p,
+		Base:   cl.Transport,
+	}
+	return &c
+}
@@ -0,0 +1,200 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    time.Millisecond,
+	MaxBackoff: 10 * time.Millisecond,
+}
+
+// failingServer fails the first failures requests for each method and path with the status, then responds with the
+// body of the request
+func failingServer(failures, status int) (*httptest.Server, map[string]int) {
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		attempts[r.Method+" "+r.URL.Path]++
+		n := attempts[r.Method+" "+r.URL.Path]
+		mu.Unlock()
+		if n <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write(b)
+	}))
+	return s, attempts
+}
+
+func TestRetryTransport(t *testing.T) {
+	s, attempts := failingServer(2, http.StatusServiceUnavailable)
+	defer s.Close()
+	cl := NewRetryClient(testRetryPolicy, nil)
+
+	resp, err := cl.Get(s.URL + "/get")
+	if err != nil {
+		t.Fatalf("error getting: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, attempts["GET /get"])
+
+	req, _ := http.NewRequest("PUT", s.URL+"/put", bytes.NewReader([]byte("content")))
+	resp, err = cl.Do(req)
+	if err != nil {
+		t.Fatalf("error putting: %v", err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "content", string(b), "body not sent again when retried")
+	assert.Equal(t, 3, attempts["PUT /put"])
+
+	resp, err = cl.Post(s.URL+"/post", "text/plain", bytes.NewReader([]byte("content")))
+	if err != nil {
+		t.Fatalf("error posting: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, attempts["POST /post"], "POST should not be retried")
+
+	// a body that cannot be sent again is not retried
+	req, _ = http.NewRequest("PUT", s.URL+"/stream", ioutil.NopCloser(bytes.NewReader([]byte("content"))))
+	resp, err = cl.Do(req)
+	if err != nil {
+		t.Fatalf("error putting: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, 1, attempts["PUT /stream"], "request without GetBody should not be retried")
+}
+
+func TestRetryTransport_Exhausted(t *testing.T) {
+	s, attempts := failingServer(5, http.StatusBadGateway)
+	defer s.Close()
+	resp, err := NewRetryClient(testRetryPolicy, nil).Get(s.URL + "/get")
+	if err != nil {
+		t.Fatalf("error getting: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, 3, attempts["GET /get"])
+
+	s, attempts = failingServer(5, http.StatusNotFound)
+	defer s.Close()
+	resp, err = NewRetryClient(testRetryPolicy, nil).Get(s.URL + "/get")
+	if err != nil {
+		t.Fatalf("error getting: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, 1, attempts["GET /get"], "not found should not be retried")
+}
+
+func TestRetryTransport_Cancel(t *testing.T) {
+	s, attempts := failingServer(5, http.StatusServiceUnavailable)
+	defer s.Close()
+	p := RetryPolicy{Attempts: 5, Backoff: time.Hour, MaxBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", s.URL+"/get", nil)
+	_, err := NewRetryClient(p, nil).Do(req)
+	assert.NotNil(t, err, "cancelled retry should error")
+	assert.Equal(t, 1, attempts["GET /get"])
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt := 1; attempt <= 6; attempt++ {
+		max := 100 * time.Millisecond << uint(attempt-1)
+		if max > time.Second {
+			max = time.Second
+		}
+		for i := 0; i < 20; i++ {
+			d := p.delay(attempt, nil)
+			if d <= 0 || d > max {
+				t.Errorf("attempt %d: delay %v not within (0, %v]", attempt, d, max)
+			}
+		}
+	}
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "120")
+	assert.Equal(t, time.Second, p.delay(1, resp), "Retry-After seconds should be capped by MaxBackoff")
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Equal(t, time.Second, p.delay(1, resp), "Retry-After date should be capped by MaxBackoff")
+	resp.Header.Set("Retry-After", "invalid")
+	assert.True(t, p.delay(1, resp) <= 100*time.Millisecond)
+
+	p = RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Hour}
+	resp.Header.Set("Retry-After", "120")
+	assert.Equal(t, 120*time.Second, p.delay(1, resp))
+	resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	d := p.delay(1, resp)
+	assert.True(t, d > 59*time.Second && d <= time.Minute, "Retry-After date not honoured: %v", d)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryTransport_Errors(t *testing.T) {
+	var tests = []struct {
+		err      error
+		attempts int
+	}{
+		{&url.Error{Op: "Get", URL: "http://example.com", Err: timeoutError{}}, 3},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, 3},
+		{io.ErrUnexpectedEOF, 3},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, 1},
+		{errors.New("tls: handshake failure"), 1},
+	}
+	for _, test := range tests {
+		var attempts int
+		rt := &RetryTransport{Policy: testRetryPolicy, Base: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			attempts++
+			return nil, test.err
+		})}
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		_, err := rt.RoundTrip(req)
+		assert.Equal(t, test.err, err)
+		assert.Equal(t, test.attempts, attempts, "attempts on error %v", test.err)
+	}
+
+	// an error caused by the context being done is not retried
+	var attempts int
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := &RetryTransport{Policy: testRetryPolicy, Base: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		attempts++
+		cancel()
+		return nil, &url.Error{Op: "Get", URL: "http://example.com", Err: timeoutError{}}
+	})}
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+	_, err := rt.RoundTrip(req)
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts, "request with a done context should not be retried")
+}
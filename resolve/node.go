@@ -0,0 +1,159 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jcmturner/gomvn/pom"
+	"github.com/jcmturner/gomvn/repo"
+)
+
+// The scopes of dependencies
+const (
+	ScopeCompile  = "compile"
+	ScopeRuntime  = "runtime"
+	ScopeProvided = "provided"
+	ScopeTest     = "test"
+	ScopeSystem   = "system"
+)
+
+// Omission is why a node of the graph was not selected
+type Omission string
+
+const (
+	// OmittedForDuplicate is a node of the same version as the node selected
+	OmittedForDuplicate Omission = "duplicate"
+	// OmittedForConflict is a node of a different version than the node selected
+	OmittedForConflict Omission = "conflict"
+	// OmittedForCycle is a node of the same artifact as one of its ancestors
+	OmittedForCycle Omission = "cycle"
+)
+
+// Node is an artifact in the dependency graph
+type Node struct {
+	// Coordinates are those of the artifact file, with the version resolved
+	Coordinates repo.Coordinates
+	// Dependency is as declared, with any dependencyManagement applied
+	Dependency pom.Dependency
+	// Scope is the scope of the artifact derived from those of the path to it
+	Scope string
+	// Optional is true for an optional direct dependency
+	Optional bool
+	// PremanagedVersion and PremanagedScope are those declared if dependencyManagement overrode them
+	PremanagedVersion string
+	PremanagedScope   string
+	// Depth is the number of dependencies from the root, which has a depth of 0
+	Depth    int
+	Parent   *Node
+	Children []*Node
+	// Omitted is why the node was not selected, empty if it was
+	Omitted Omission
+	// Winner is the node selected instead of this one, the ancestor for a cycle
+	Winner *Node
+}
+
+// Graph is the graph of the dependencies of the root, including those omitted
+type Graph struct {
+	Root *Node
+}
+
+// Key returns the groupId:artifactId:extension:classifier that identifies the artifact, regardless of version, when
+// mediating between conflicting nodes
+func (n *Node) Key() string {
+	c := n.Coordinates
+	return fmt.Sprintf("%s:%s:%s:%s", c.GroupID, c.ArtifactID, c.Extension, c.Classifier)
+}
+
+// Selected reports if the node was selected
+func (n *Node) Selected() bool {
+	return n.Omitted == ""
+}
+
+// String returns the node as mvn dependency:tree -Dverbose does, such as
+// "(org.example:example:jar:1.0:compile - omitted for conflict with 2.0)"
+func (n *Node) String() string {
+	s := n.Coordinates.String()
+	if n.Scope != "" {
+		s += ":" + n.Scope
+	}
+	var notes []string
+	if n.PremanagedVersion != "" {
+		notes = append(notes, "version managed from "+n.PremanagedVersion)
+	}
+	if n.PremanagedScope != "" {
+		notes = append(notes, "scope managed from "+n.PremanagedScope)
+	}
+	switch n.Omitted {
+	case "":
+		if n.Optional {
+			s += " (optional)"
+		}
+		if len(notes) > 0 {
+			s += " (" + strings.Join(notes, "; ") + ")"
+		}
+		return s
+	case OmittedForConflict:
+		notes = append(notes, "omitted for conflict with "+n.Winner.Coordinates.Version)
+	default:
+		notes = append(notes, "omitted for "+string(n.Omitted))
+	}
+	return "(" + s + " - " + strings.Join(notes, "; ") + ")"
+}
+
+// Selected returns the nodes selected, other than the root, nearest first
+func (g Graph) Selected() []*Node {
+	var ns []*Node
+	g.walk(func(n *Node) {
+		if n != g.Root && n.Selected() {
+			ns = append(ns, n)
+		}
+	})
+	return ns
+}
+
+// Omitted returns the nodes omitted, nearest first
+func (g Graph) Omitted() []*Node {
+	var ns []*Node
+	g.walk(func(n *Node) {
+		if !n.Selected() {
+			ns = append(ns, n)
+		}
+	})
+	return ns
+}
+
+// walk calls f on each node of the graph breadth first
+func (g Graph) walk(f func(n *Node)) {
+	if g.Root == nil {
+		return
+	}
+	queue := []*Node{g.Root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		f(n)
+		queue = append(queue, n.Children...)
+	}
+}
+
+// String returns the graph as a tree as mvn dependency:tree -Dverbose does
+func (g Graph) String() string {
+	if g.Root == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(g.Root.String() + "\n")
+	tree(&b, g.Root, "")
+	return b.String()
+}
+
+func tree(b *strings.Builder, n *Node, indent string) {
+	for i, c := range n.Children {
+		branch, next := "+- ", "|  "
+		if i == len(n.Children)-1 {
+			branch, next = "\\- ", "   "
+		}
+		b.WriteString(indent + branch + c.String() + "\n")
+		tree(b, c, indent+next)
+	}
+}
@@ -0,0 +1,558 @@
+// Package resolve resolves the transitive dependencies of a POM following maven's mediation rules.
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jcmturner/gomvn/metadata"
+	"github.com/jcmturner/gomvn/pom"
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/jcmturner/gomvn/version"
+)
+
+const wildcard = "*"
+
+// artifactTypes are the extension and classifier of the artifact files of the dependency types maven knows, other
+// types are the extension of the file
+var artifactTypes = map[string]struct{ extension, classifier string }{
+	"test-jar":     {"jar", "tests"},
+	"ejb-client":   {"jar", "client"},
+	"ejb":          {"jar", ""},
+	"maven-plugin": {"jar", ""},
+	"java-source":  {"jar", "sources"},
+	"javadoc":      {"jar", "javadoc"},
+}
+
+// scopeWidth orders the scopes when widening the scope of a node to that of the nodes it was selected over
+var scopeWidth = map[string]int{
+	ScopeTest:     1,
+	ScopeProvided: 2,
+	ScopeSystem:   3,
+	ScopeRuntime:  4,
+	ScopeCompile:  5,
+}
+
+// VersionConflict is returned when no version of an artifact is within all the version ranges the dependencies on it
+// require
+type VersionConflict struct {
+	ErrorString string
+}
+
+func (e VersionConflict) Error() string {
+	return e.ErrorString
+}
+
+// Resolve returns the graph of the transitive dependencies of the root POM. See ResolveContext.
+func Resolve(repoURL string, root pom.POM, props map[string]string, cl *http.Client) (Graph, error) {
+	return ResolveContext(context.Background(), repoURL, root, props, repo.FetchOptions{}, cl)
+}
+
+// ResolveContext returns the graph of the transitive dependencies of the root POM with the context, getting the
+// effective POMs of the dependencies, interpolated with the properties given, and the metadata to resolve version
+// ranges from the repository and verifying them according to the options. The root should be an effective POM, such
+// as that from pom.LoadEffective.
+//
+// As with maven:
+//   - the nearest dependency on an artifact is selected, the first declared where they are equally near. The others
+//     are in the graph, omitted for conflict or as duplicates, but their dependencies are not.
+//   - test and provided scoped dependencies, and optional dependencies, are not transitive. The scope of a transitive
+//     dependency is derived from that of the dependency on it and widened to that of the other dependencies on the
+//     same artifact, unless it is a direct dependency.
+//   - exclusions, where groupId and artifactId can be *, apply to all the dependencies beneath the dependency
+//     declaring them.
+//   - the dependencyManagement of the root overrides the version and scope, and adds to the exclusions, of the
+//     transitive dependencies. That of each POM provides the versions of its own dependencies that have none.
+//   - a version range resolves to the highest version within it in the repository. If the nearest node of an artifact
+//     is not within the range of another dependency on it the selection backtracks, as NearestVersionSelector does, to
+//     the nearest version within all the ranges, the highest of those equally near, considering every version in the
+//     repository within the range of a node. A VersionConflict error is returned only if no version is within them.
+//
+// Relocations, profiles and the repositories declared by the POMs are not taken into account, and system scoped
+// dependencies are not resolved further.
+func ResolveContext(ctx context.Context, repoURL string, root pom.POM, props map[string]string, opts repo.FetchOptions, cl *http.Client) (Graph, error) {
+	r := &resolver{
+		repoURL:  repoURL,
+		props:    props,
+		opts:     opts,
+		cl:       cl,
+		managed:  managed(root),
+		poms:     make(map[string]pom.POM),
+		versions: make(map[string]version.Versions),
+		forced:   make(map[string]string),
+	}
+	for {
+		g, again, err := r.resolve(ctx, root)
+		if !again {
+			return g, err
+		}
+	}
+}
+
+// resolve builds the graph of the root breadth first. It returns true if the graph must be built again because the
+// version selected for an artifact had to change.
+func (r *resolver) resolve(ctx context.Context, root pom.POM) (Graph, bool, error) {
+	r.selected = make(map[string]*Node)
+	r.groups = make(map[string][]*Node)
+	r.pending = make(map[string][]*Node)
+	r.constraints = make(map[string][]constraint)
+	g := Graph{Root: &Node{
+		Coordinates: root.Coordinates(),
+		Dependency: pom.Dependency{
+			GroupID:    root.GroupID,
+			ArtifactID: root.ArtifactID,
+			Version:    root.Version,
+			Type:       root.Packaging,
+		},
+	}}
+	queue := []*Node{g.Root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		p := root
+		if n != g.Root {
+			var err error
+			p, err = r.pom(ctx, n.Coordinates)
+			if err != nil {
+				return g, false, err
+			}
+		}
+		for _, d := range dependencies(p) {
+			c, err := r.node(ctx, n, d)
+			if err != nil {
+				return g, false, err
+			}
+			if c == nil {
+				continue
+			}
+			n.Children = append(n.Children, c)
+			ok, again, err := r.mediate(c)
+			if err != nil || again {
+				return g, again, err
+			}
+			if ok && c.Scope != ScopeSystem {
+				queue = append(queue, c)
+			}
+		}
+	}
+	for key := range r.pending {
+		// the nodes of the version chosen by backtracking are no longer in the graph
+		return g, false, r.conflict(key)
+	}
+	return g, false, nil
+}
+
+type resolver struct {
+	repoURL string
+	props   map[string]string
+	opts    repo.FetchOptions
+	cl      *http.Client
+	// managed is the dependencyManagement of the root by the Key of the dependency
+	managed map[string]pom.Dependency
+	// poms are the effective POMs of the dependencies by groupId:artifactId:version
+	poms map[string]pom.POM
+	// versions are those in the metadata of the artifacts by groupId:artifactId
+	versions map[string]version.Versions
+	// forced are the versions chosen by backtracking by the Key of the artifact, kept for every pass
+	forced map[string]string
+	// constraints are the version ranges required of the artifacts by their Key, by the nodes of this pass
+	constraints map[string][]constraint
+	// selected are the nodes selected by their Key
+	selected map[string]*Node
+	// groups are the nodes of each artifact by its Key, other than those omitted for cycles
+	groups map[string][]*Node
+	// pending are the nodes omitted for a version chosen by backtracking that has yet to be selected, by their Key
+	pending map[string][]*Node
+}
+
+// constraint is a version range required of an artifact by a dependency of the parent
+type constraint struct {
+	rng    version.Range
+	spec   string
+	parent string
+}
+
+// managed returns the dependencyManagement of the POM by the Key of the dependency, the first declaration winning
+func managed(p pom.POM) map[string]pom.Dependency {
+	m := make(map[string]pom.Dependency)
+	if p.DependencyManagement == nil || p.DependencyManagement.Dependencies == nil {
+		return m
+	}
+	for _, d := range *p.DependencyManagement.Dependencies {
+		if _, ok := m[d.Key()]; !ok && !d.IsImport() {
+			m[d.Key()] = d
+		}
+	}
+	return m
+}
+
+// dependencies returns the dependencies of the POM with their version, scope, optional and system path provided by
+// its dependencyManagement if they have none, and with its exclusions added, as maven does when building the model
+func dependencies(p pom.POM) []pom.Dependency {
+	if p.Dependencies == nil {
+		return nil
+	}
+	m := managed(p)
+	deps := make([]pom.Dependency, len(*p.Dependencies))
+	for i, d := range *p.Dependencies {
+		if md, ok := m[d.Key()]; ok {
+			if d.Version == "" {
+				d.Version = md.Version
+			}
+			if d.Scope == "" {
+				d.Scope = md.Scope
+			}
+			if d.Optional == "" {
+				d.Optional = md.Optional
+			}
+			if d.SystemPath == "" {
+				d.SystemPath = md.SystemPath
+			}
+			d.Exclusions = addExclusions(d.Exclusions, md.Exclusions)
+		}
+		deps[i] = d
+	}
+	return deps
+}
+
+func addExclusions(es, add *[]pom.Exclusion) *[]pom.Exclusion {
+	if add == nil || len(*add) == 0 {
+		return es
+	}
+	var m []pom.Exclusion
+	if es != nil {
+		m = append(m, *es...)
+	}
+	for _, a := range *add {
+		var found bool
+		for _, e := range m {
+			if e == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			m = append(m, a)
+		}
+	}
+	return &m
+}
+
+// node returns the node of the dependency of the parent, or nil if it is not a transitive dependency or is excluded
+func (r *resolver) node(ctx context.Context, parent *Node, d pom.Dependency) (*Node, error) {
+	if d.Scope == "" {
+		d.Scope = ScopeCompile
+	}
+	n := &Node{Parent: parent, Depth: parent.Depth + 1}
+	if n.Depth > 1 {
+		if d.Scope == ScopeTest || d.Scope == ScopeProvided || d.IsOptional() {
+			return nil, nil
+		}
+	}
+	if excluded(parent, d) {
+		return nil, nil
+	}
+	if md, ok := r.managed[d.Key()]; ok && n.Depth > 1 {
+		if md.Version != "" && md.Version != d.Version {
+			n.PremanagedVersion = d.Version
+			d.Version = md.Version
+		}
+		if md.Scope != "" && md.Scope != d.Scope {
+			n.PremanagedScope = d.Scope
+			d.Scope = md.Scope
+		}
+		if md.SystemPath != "" {
+			d.SystemPath = md.SystemPath
+		}
+		d.Exclusions = addExclusions(d.Exclusions, md.Exclusions)
+	}
+	if d.Version == "" {
+		return nil, fmt.Errorf("dependency %s of %s has no version", d.Key(), parent.Coordinates)
+	}
+	v := d.Version
+	if d.Scope != ScopeSystem {
+		var err error
+		v, err = r.version(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c := repo.Coordinates{
+		GroupID:    d.GroupID,
+		ArtifactID: d.ArtifactID,
+		Extension:  d.Type,
+		Classifier: d.Classifier,
+		Version:    v,
+	}
+	if c.Extension == "" {
+		c.Extension = repo.DefaultExtension
+	}
+	if t, ok := artifactTypes[d.Type]; ok {
+		c.Extension = t.extension
+		if c.Classifier == "" {
+			c.Classifier = t.classifier
+		}
+	}
+	n.Coordinates = c
+	if fv, ok := r.forced[n.Key()]; ok && d.Scope != ScopeSystem {
+		// the version chosen by backtracking is used by the dependencies with a range including it
+		if rng, err := version.ParseRange(d.Version); err == nil && !rng.IsSoft() {
+			if v, err := version.New(fv); err == nil && rng.Contains(v) {
+				n.Coordinates.Version = fv
+			}
+		}
+	}
+	n.Dependency = d
+	n.Scope = derive(parent.Scope, d.Scope)
+	n.Optional = n.Depth == 1 && d.IsOptional()
+	return n, nil
+}
+
+// excluded reports if the exclusions of the node or its ancestors exclude the dependency
+func excluded(n *Node, d pom.Dependency) bool {
+	for ; n != nil; n = n.Parent {
+		if n.Dependency.Exclusions == nil {
+			continue
+		}
+		for _, e := range *n.Dependency.Exclusions {
+			if (e.GroupID == wildcard || e.GroupID == d.GroupID) && (e.ArtifactID == wildcard || e.ArtifactID == d.ArtifactID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// derive returns the scope of a dependency declared with scope s by a dependency of the parent scope
+func derive(parent, s string) string {
+	switch {
+	case s == ScopeSystem || s == ScopeTest:
+		return s
+	case parent == "" || parent == ScopeCompile:
+		return s
+	case parent == ScopeTest || parent == ScopeRuntime:
+		return parent
+	case parent == ScopeSystem || parent == ScopeProvided:
+		return ScopeProvided
+	}
+	return ScopeRuntime
+}
+
+// version returns the version of the dependency, resolving a version range to the highest version within it in the
+// repository. SNAPSHOT versions are only considered if the range refers to one.
+func (r *resolver) version(ctx context.Context, d pom.Dependency) (string, error) {
+	rng, err := version.ParseRange(d.Version)
+	if err != nil {
+		return "", fmt.Errorf("invalid version of dependency %s: %v", d.Key(), err)
+	}
+	if rng.IsSoft() {
+		return d.Version, nil
+	}
+	ga := d.GroupID + ":" + d.ArtifactID
+	vs, ok := r.versions[ga]
+	if !ok {
+		md, err := metadata.GetContext(ctx, r.repoURL, d.GroupID, d.ArtifactID, r.opts, r.cl)
+		if err != nil {
+			return "", fmt.Errorf("error getting versions of %s: %v", ga, err)
+		}
+		if md.Versioning.Versions != nil {
+			vs = *md.Versioning.Versions
+		}
+		r.versions[ga] = vs
+	}
+	v, err := r.available(d, rng).Highest(rng)
+	if err != nil {
+		return "", version.NoMatch{
+			ErrorString: fmt.Sprintf("no version of %s within %s", ga, d.Version),
+		}
+	}
+	return v.String(), nil
+}
+
+// available returns the versions of the artifact of the dependency in its metadata, which must have been fetched.
+// SNAPSHOT versions are only included if the range of the dependency refers to one.
+func (r *resolver) available(d pom.Dependency, rng version.Range) version.Versions {
+	snapshots := refersToSnapshot(rng)
+	var vs version.Versions
+	for _, v := range r.versions[d.GroupID+":"+d.ArtifactID] {
+		if snapshots || !v.IsSnapshot() {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// refersToSnapshot indicates if the recommended version or a bound of the range is a SNAPSHOT version
+func refersToSnapshot(rng version.Range) bool {
+	if rng.Recommended != nil && rng.Recommended.IsSnapshot() {
+		return true
+	}
+	for _, res := range rng.Restrictions {
+		if (res.Lower != nil && res.Lower.IsSnapshot()) || (res.Upper != nil && res.Upper.IsSnapshot()) {
+			return true
+		}
+	}
+	return false
+}
+
+// pom returns the effective POM of the artifact
+func (r *resolver) pom(ctx context.Context, c repo.Coordinates) (pom.POM, error) {
+	gav := fmt.Sprintf("%s:%s:%s", c.GroupID, c.ArtifactID, c.Version)
+	if p, ok := r.poms[gav]; ok {
+		return p, nil
+	}
+	p, err := pom.EffectiveContext(ctx, r.repoURL, c.GroupID, c.ArtifactID, c.Version, r.props, r.opts, r.cl)
+	if _, ok := err.(pom.InterpolationError); err != nil && !ok {
+		return p, fmt.Errorf("error getting POM of %s: %v", gav, err)
+	}
+	r.poms[gav] = p
+	return p, nil
+}
+
+// mediate selects the node if it is the first, and so nearest, node of its artifact, otherwise omits it. Where a
+// version was chosen for the artifact by backtracking only a node of that version is selected. It returns true if the
+// node was selected, or true for again if the version selected is not within the ranges required of the artifact and
+// the graph must be built again with the version chosen by backtracking instead.
+func (r *resolver) mediate(n *Node) (selected, again bool, err error) {
+	key := n.Key()
+	for a := n.Parent; a != nil; a = a.Parent {
+		if a.Key() == key {
+			n.Omitted = OmittedForCycle
+			n.Winner = a
+			return false, false, nil
+		}
+	}
+	r.groups[key] = append(r.groups[key], n)
+	r.constrain(key, n)
+	w, ok := r.selected[key]
+	if !ok {
+		if fv, ok := r.forced[key]; ok && n.Coordinates.Version != fv {
+			n.Omitted = OmittedForConflict
+			r.pending[key] = append(r.pending[key], n)
+			return false, false, nil
+		}
+		r.selected[key] = n
+		for _, p := range r.pending[key] {
+			p.Winner = n
+			widen(n, p.Scope)
+		}
+		delete(r.pending, key)
+		if !r.acceptable(key, n.Coordinates.Version) {
+			again, err := r.backtrack(key)
+			return false, again, err
+		}
+		return true, false, nil
+	}
+	n.Winner = w
+	n.Omitted = OmittedForConflict
+	if n.Coordinates.Version == w.Coordinates.Version {
+		n.Omitted = OmittedForDuplicate
+	}
+	if !r.acceptable(key, w.Coordinates.Version) {
+		again, err := r.backtrack(key)
+		return false, again, err
+	}
+	widen(w, n.Scope)
+	return false, false, nil
+}
+
+// constrain records the version range the dependency of the node requires of its artifact, if it is not a soft
+// requirement
+func (r *resolver) constrain(key string, n *Node) {
+	if n.Scope == ScopeSystem {
+		return
+	}
+	rng, err := version.ParseRange(n.Dependency.Version)
+	if err != nil || rng.IsSoft() {
+		return
+	}
+	c := constraint{rng: rng, spec: n.Dependency.Version, parent: n.Parent.Coordinates.String()}
+	for _, e := range r.constraints[key] {
+		if e.spec == c.spec && e.parent == c.parent {
+			return
+		}
+	}
+	r.constraints[key] = append(r.constraints[key], c)
+}
+
+// acceptable reports if the version is within all the ranges required of the artifact
+func (r *resolver) acceptable(key, v string) bool {
+	ver, err := version.New(v)
+	if err != nil {
+		return len(r.constraints[key]) == 0
+	}
+	for _, c := range r.constraints[key] {
+		if !c.rng.Contains(ver) {
+			return false
+		}
+	}
+	return true
+}
+
+// backtrack chooses the version of the artifact to select in place of one not within all the ranges required of it:
+// the version of the nearest node that is, the highest of those equally near, where a node with a range may have any
+// version in the repository within it. It returns true to build the graph again with the version chosen, or a
+// VersionConflict error if no version is within all the ranges.
+func (r *resolver) backtrack(key string) (bool, error) {
+	var best version.Version
+	depth := -1
+	for _, n := range r.groups[key] {
+		var vs version.Versions
+		if rng, err := version.ParseRange(n.Dependency.Version); err == nil && !rng.IsSoft() && n.Scope != ScopeSystem {
+			for _, v := range r.available(n.Dependency, rng) {
+				if rng.Contains(v) {
+					vs = append(vs, v)
+				}
+			}
+		} else if v, err := version.New(n.Coordinates.Version); err == nil {
+			vs = append(vs, v)
+		}
+		for _, v := range vs {
+			if !r.acceptable(key, v.String()) {
+				continue
+			}
+			if depth < 0 || n.Depth < depth || (n.Depth == depth && best.Less(v)) {
+				best, depth = v, n.Depth
+			}
+		}
+	}
+	if depth < 0 || r.forced[key] == best.String() {
+		return false, r.conflict(key)
+	}
+	r.forced[key] = best.String()
+	return true, nil
+}
+
+// conflict returns the VersionConflict error of the ranges required of the artifact
+func (r *resolver) conflict(key string) error {
+	var required []string
+	for _, c := range r.constraints[key] {
+		required = append(required, fmt.Sprintf("%s required by %s", c.spec, c.parent))
+	}
+	return VersionConflict{
+		ErrorString: fmt.Sprintf("no version of %s is within all of %s", key, strings.Join(required, ", ")),
+	}
+}
+
+// widen widens the scope of the node selected to that of a node omitted in its favour, unless it is a direct
+// dependency, deriving the scopes of its dependencies again
+func widen(n *Node, scope string) {
+	if n.Depth <= 1 || scopeWidth[scope] <= scopeWidth[n.Scope] {
+		return
+	}
+	n.Scope = scope
+	for _, c := range n.Children {
+		s := derive(n.Scope, c.Dependency.Scope)
+		switch c.Omitted {
+		case "":
+			widen(c, s)
+		case OmittedForCycle:
+			c.Scope = s
+		default:
+			c.Scope = s
+			widen(c.Winner, s)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jcmturner/gomvn/metadata"
+	"github.com/jcmturner/gomvn/pom"
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+// project returns a POM of com.example:artifactID:version with the inner XML
+func project(artifactID, version, inner string) string {
+	return fmt.Sprintf(`<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>com.example</groupId>
+  <artifactId>%s</artifactId>
+  <version>%s</version>
+  %s
+</project>`, artifactID, version, inner)
+}
+
+// deps returns the dependencies element of the dependencies given as artifactId:version of com.example, each
+// optionally followed by inner XML after a space
+func deps(ds ...string) string {
+	var x []string
+	for _, d := range ds {
+		var inner string
+		if i := strings.Index(d, " "); i > 0 {
+			d, inner = d[:i], d[i+1:]
+		}
+		c := strings.SplitN(d, ":", 2)
+		x = append(x, fmt.Sprintf("<dependency><groupId>com.example</groupId><artifactId>%s</artifactId><version>%s</version>%s</dependency>", c[0], c[1], inner))
+	}
+	return "<dependencies>" + strings.Join(x, "") + "</dependencies>"
+}
+
+// repoServer serves the POMs, given by artifactId:version of com.example, and the metadata of the artifacts with
+// the versions given by artifactId
+func repoServer(t *testing.T, poms map[string]string, versions map[string][]string) *httptest.Server {
+	files := make(map[string][]byte)
+	for av, p := range poms {
+		c := strings.Split(av, ":")
+		files[fmt.Sprintf("/com/example/%s/%s/%s-%s.pom", c[0], c[1], c[0], c[1])] = []byte(p)
+	}
+	for a, vs := range versions {
+		md := metadata.New("com.example", a)
+		for _, v := range vs {
+			md.AddVersion(v)
+		}
+		b, err := md.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[fmt.Sprintf("/com/example/%s/maven-metadata.xml", a)] = b
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b, ok := files[r.URL.Path]; ok {
+			w.Write(b)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func root(t *testing.T, inner string) pom.POM {
+	var p pom.POM
+	if err := p.Unmarshal([]byte(project("app", "1.0", inner))); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+var ignore = repo.FetchOptions{ChecksumPolicy: repo.ChecksumPolicyIgnore}
+
+func TestResolveContext(t *testing.T) {
+	s := repoServer(t, map[string]string{
+		"a:1.0": project("a", "1.0", deps("c:1.0", "d:1.0", "p:1.0 <scope>provided</scope>",
+			"q:1.0 <optional>true</optional>", "app:1.0")),
+		"b:1.0": project("b", "1.0", deps("c:2.0", "e:1.0", "f:1.0 <scope>runtime</scope>")),
+		"c:1.0": project("c", "1.0", deps("h:1.0")),
+		"d:3.0": project("d", "3.0", ""),
+		"e:1.0": project("e", "1.0", ""),
+		"f:1.0": project("f", "1.0", deps("k:1.0")),
+		"g:1.0": project("g", "1.0", ""),
+		"h:1.0": project("h", "1.0", deps("x:1.0")),
+		"k:1.0": project("k", "1.0", ""),
+		"o:1.0": project("o", "1.0", deps("g:1.0")),
+		"r:1.5": project("r", "1.5", deps("e:1.0")),
+		"t:1.0": project("t", "1.0", deps("f:1.0")),
+		"x:1.0": project("x", "1.0", ""),
+	}, map[string][]string{
+		"r": {"1.0", "1.5", "1.6-SNAPSHOT", "2.0"},
+	})
+	defer s.Close()
+
+	p := root(t, deps(
+		"a:1.0",
+		"t:1.0 <scope>test</scope>",
+		"b:1.0 <exclusions><exclusion><groupId>com.example</groupId><artifactId>e</artifactId></exclusion></exclusions>",
+		"o:1.0 <optional>true</optional>",
+		"r:[1.0,2.0) <exclusions><exclusion><groupId>*</groupId><artifactId>*</artifactId></exclusion></exclusions>",
+	)+"<dependencyManagement>"+deps("d:3.0", "x:1.0 <scope>runtime</scope>")+"</dependencyManagement>")
+	g, err := ResolveContext(context.Background(), s.URL, p, nil, ignore, nil)
+	if err != nil {
+		t.Fatalf("error resolving: %v", err)
+	}
+	assert.Equal(t, `com.example:app:jar:1.0
++- com.example:a:jar:1.0:compile
+|  +- com.example:c:jar:1.0:compile
+|  |  \- com.example:h:jar:1.0:compile
+|  |     \- com.example:x:jar:1.0:runtime (scope managed from compile)
+|  +- com.example:d:jar:3.0:compile (version managed from 1.0)
+|  \- (com.example:app:jar:1.0:compile - omitted for cycle)
++- com.example:t:jar:1.0:test
+|  \- com.example:f:jar:1.0:runtime
+|     \- com.example:k:jar:1.0:runtime
++- com.example:b:jar:1.0:compile
+|  +- (com.example:c:jar:2.0:compile - omitted for conflict with 1.0)
+|  \- (com.example:f:jar:1.0:runtime - omitted for duplicate)
++- com.example:o:jar:1.0:compile (optional)
+|  \- com.example:g:jar:1.0:compile
+\- com.example:r:jar:1.5:compile
+`, g.String())
+
+	var selected []string
+	for _, n := range g.Selected() {
+		selected = append(selected, n.Coordinates.String()+":"+n.Scope)
+	}
+	assert.Equal(t, []string{
+		"com.example:a:jar:1.0:compile",
+		"com.example:t:jar:1.0:test",
+		"com.example:b:jar:1.0:compile",
+		"com.example:o:jar:1.0:compile",
+		"com.example:r:jar:1.5:compile",
+		"com.example:c:jar:1.0:compile",
+		"com.example:d:jar:3.0:compile",
+		"com.example:f:jar:1.0:runtime",
+		"com.example:g:jar:1.0:compile",
+		"com.example:h:jar:1.0:compile",
+		"com.example:k:jar:1.0:runtime",
+		"com.example:x:jar:1.0:runtime",
+	}, selected, "selected nodes should be nearest first")
+	omitted := g.Omitted()
+	if assert.Equal(t, 3, len(omitted)) {
+		assert.Equal(t, OmittedForCycle, omitted[0].Omitted)
+		assert.Equal(t, g.Root, omitted[0].Winner)
+		assert.Equal(t, OmittedForConflict, omitted[1].Omitted)
+		assert.Equal(t, "1.0", omitted[1].Winner.Coordinates.Version)
+		assert.Equal(t, OmittedForDuplicate, omitted[2].Omitted)
+	}
+}
+
+func TestResolveContext_Types(t *testing.T) {
+	s := repoServer(t, map[string]string{
+		"a:1.0": project("a", "1.0", ""),
+	}, nil)
+	defer s.Close()
+	p := root(t, deps("a:1.0 <type>test-jar</type><scope>test</scope>", "a:1.0"))
+	g, err := ResolveContext(context.Background(), s.URL, p, nil, ignore, nil)
+	if err != nil {
+		t.Fatalf("error resolving: %v", err)
+	}
+	var selected []string
+	for _, n := range g.Selected() {
+		selected = append(selected, n.String())
+	}
+	assert.Equal(t, []string{"com.example:a:jar:tests:1.0:test", "com.example:a:jar:1.0:compile"}, selected)
+}
+
+func TestResolveContext_Backtrack(t *testing.T) {
+	s := repoServer(t, map[string]string{
+		"a:1.0": project("a", "1.0", deps("x:1.0")),
+		"b:1.0": project("b", "1.0", deps("x:[2.0,3.0)")),
+		"c:1.0": project("c", "1.0", deps("d:1.0")),
+		"d:1.0": project("d", "1.0", deps("x:[1.5,2.5]")),
+		"e:1.0": project("e", "1.0", deps("x:[1.0,3.0]")),
+		"x:1.0": project("x", "1.0", deps("y:1.0")),
+		"x:2.0": project("x", "2.0", ""),
+		"x:3.0": project("x", "3.0", ""),
+		"y:1.0": project("y", "1.0", ""),
+	}, map[string][]string{
+		"x": {"1.0", "2.0", "3.0"},
+	})
+	defer s.Close()
+
+	g, err := ResolveContext(context.Background(), s.URL, root(t, deps("a:1.0", "b:1.0")), nil, ignore, nil)
+	if err != nil {
+		t.Fatalf("error resolving: %v", err)
+	}
+	assert.Equal(t, `com.example:app:jar:1.0
++- com.example:a:jar:1.0:compile
+|  \- (com.example:x:jar:1.0:compile - omitted for conflict with 2.0)
+\- com.example:b:jar:1.0:compile
+   \- com.example:x:jar:2.0:compile
+`, g.String(), "the nearest version within the range should be selected")
+
+	// the highest version of e's range is not within that of the deeper dependency of d, so another version within
+	// e's range is chosen
+	g, err = ResolveContext(context.Background(), s.URL, root(t, deps("e:1.0", "c:1.0")), nil, ignore, nil)
+	if err != nil {
+		t.Fatalf("error resolving: %v", err)
+	}
+	assert.Equal(t, `com.example:app:jar:1.0
++- com.example:e:jar:1.0:compile
+|  \- com.example:x:jar:2.0:compile
+\- com.example:c:jar:1.0:compile
+   \- com.example:d:jar:1.0:compile
+      \- (com.example:x:jar:2.0:compile - omitted for duplicate)
+`, g.String())
+
+	// the version of c chosen by backtracking has a dependency with a different range to that of the version first
+	// selected, which must not be required in the graph built again as the first version is no longer in it
+	s = repoServer(t, map[string]string{
+		"c:1.0": project("c", "1.0", deps("e:[1.0]")),
+		"c:2.0": project("c", "2.0", deps("e:[2.0]")),
+		"d:1.0": project("d", "1.0", deps("c:[1.0]")),
+		"e:1.0": project("e", "1.0", ""),
+		"e:2.0": project("e", "2.0", ""),
+	}, map[string][]string{
+		"c": {"1.0", "2.0"},
+		"e": {"1.0", "2.0"},
+	})
+	defer s.Close()
+	g, err = ResolveContext(context.Background(), s.URL, root(t, deps("c:[1.0,2.0]", "d:1.0")), nil, ignore, nil)
+	if err != nil {
+		t.Fatalf("error resolving: %v", err)
+	}
+	assert.Equal(t, `com.example:app:jar:1.0
++- com.example:c:jar:1.0:compile
+|  \- com.example:e:jar:1.0:compile
+\- com.example:d:jar:1.0:compile
+   \- (com.example:c:jar:1.0:compile - omitted for duplicate)
+`, g.String())
+}
+
+func TestResolveContext_Snapshots(t *testing.T) {
+	s := repoServer(t, map[string]string{
+		"r:1.0":          project("r", "1.0", ""),
+		"r:2.0-SNAPSHOT": project("r", "2.0-SNAPSHOT", ""),
+	}, map[string][]string{
+		"r": {"1.0", "2.0-SNAPSHOT"},
+	})
+	defer s.Close()
+
+	for spec, want := range map[string]string{
+		"[1.0,)":             "1.0",
+		"[1.0-snapshot,)":    "2.0-SNAPSHOT",
+		"[1.0,2.0-SNAPSHOT]": "2.0-SNAPSHOT",
+	} {
+		g, err := ResolveContext(context.Background(), s.URL, root(t, deps("r:"+spec)), nil, ignore, nil)
+		if err != nil {
+			t.Fatalf("error resolving %s: %v", spec, err)
+		}
+		assert.Equal(t, want, g.Root.Children[0].Coordinates.Version, "version of %s", spec)
+	}
+}
+
+func TestResolveContext_Errors(t *testing.T) {
+	s := repoServer(t, map[string]string{
+		"a:1.0": project("a", "1.0", deps("x:1.0")),
+		"b:1.0": project("b", "1.0", deps("x:[2.0,3.0)")),
+		"c:1.0": project("c", "1.0", deps("x:[5.0,)")),
+		"d:1.0": project("d", "1.0", deps("x:[1.0,1.5)")),
+		"x:1.0": project("x", "1.0", ""),
+		"x:2.0": project("x", "2.0", ""),
+	}, map[string][]string{
+		"x": {"1.0", "2.0"},
+	})
+	defer s.Close()
+
+	_, err := ResolveContext(context.Background(), s.URL, root(t, deps("a:1.0", "b:1.0", "d:1.0")), nil, ignore, nil)
+	if _, ok := err.(VersionConflict); !ok {
+		t.Fatalf("expected VersionConflict, got: %v", err)
+	}
+	assert.Contains(t, err.Error(), "no version of com.example:x:jar: is within all of [2.0,3.0) required by com.example:b:jar:1.0, [1.0,1.5) required by com.example:d:jar:1.0")
+	_, err = ResolveContext(context.Background(), s.URL, root(t, deps("c:1.0")), nil, ignore, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no version of com.example:x within [5.0,)")
+	}
+	_, err = ResolveContext(context.Background(), s.URL, root(t, strings.Replace(deps("a:1.0"), "<version>1.0</version>", "", 1)), nil, ignore, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has no version")
+	}
+}
@@ -0,0 +1,241 @@
+package settings
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jcmturner/gomvn/pom"
+	"github.com/jcmturner/gomvn/repo"
+)
+
+const (
+	CentralID  = "central"
+	CentralURL = "https://repo.maven.apache.org/maven2"
+
+	wildcard         = "*"
+	externalWildcard = "external:*"
+	externalHTTP     = "external:http:*"
+	defaultProxyPort = 8080
+)
+
+// Route is where the requests for a repository are sent after applying the mirrors and proxies of the settings
+type Route struct {
+	// ID is the id of the repository, or of the mirror if it is mirrored, that credentials are found by
+	ID string
+	// URL is the URL requests for the repository are sent to
+	URL string
+	// Mirror is the mirror of the repository, nil if it is not mirrored
+	Mirror *Mirror
+	// Client sends requests through the proxies of the settings, authenticated with the credentials of the server
+	// with the id of the route if there is one
+	Client *http.Client
+}
+
+// Repository returns the repository with the id from the active profiles, or maven central if the id is central and
+// it is not overridden.
+func (s *Settings) Repository(id string) (pom.Repository, bool) {
+	for _, p := range s.Active() {
+		if p.Repositories == nil {
+			continue
+		}
+		for _, r := range *p.Repositories {
+			if r.ID == id {
+				return r, true
+			}
+		}
+	}
+	if id == CentralID {
+		return pom.Repository{ID: CentralID, URL: CentralURL}, true
+	}
+	return pom.Repository{}, false
+}
+
+// Route returns where requests for the repository with the id and URL are sent. If the URL is empty the repository is
+// looked up by its id with Repository. An encrypted password of the server must first be decrypted with
+// DecryptPasswords.
+func (s *Settings) Route(id, repoURL string) (Route, error) {
+	if repoURL == "" {
+		r, ok := s.Repository(id)
+		if !ok {
+			return Route{}, NotFound{
+				ErrorString: fmt.Sprintf("no repository with id %s in the active profiles of the settings", id),
+			}
+		}
+		repoURL = r.URL
+	}
+	rt := Route{ID: id, URL: repoURL}
+	if m, ok := s.MirrorOf(id, repoURL); ok {
+		if m.Blocked {
+			return rt, fmt.Errorf("repository %s (%s) is blocked by mirror %s", id, repoURL, m.ID)
+		}
+		rt.ID = m.ID
+		rt.URL = m.URL
+		rt.Mirror = &m
+	}
+	rt.Client = &http.Client{Transport: s.Transport()}
+	if srv, ok := s.Server(rt.ID); ok {
+		auth, err := s.Credentials(srv.ID)
+		if err != nil {
+			return rt, err
+		}
+		rt.Client, err = repo.NewAuthClient(rt.URL, auth, rt.Client)
+		if err != nil {
+			return rt, err
+		}
+	}
+	return rt, nil
+}
+
+// MirrorOf returns the mirror of the repository with the id and URL. As with maven, a mirror of exactly the id is
+// preferred, otherwise the first mirror with a matching mirrorOf pattern is returned.
+func (s *Settings) MirrorOf(id, repoURL string) (Mirror, bool) {
+	if s.Mirrors == nil {
+		return Mirror{}, false
+	}
+	for _, m := range *s.Mirrors {
+		if m.MirrorOf == id {
+			return m, true
+		}
+	}
+	for _, m := range *s.Mirrors {
+		if matchMirrorOf(m.MirrorOf, id, repoURL) {
+			return m, true
+		}
+	}
+	return Mirror{}, false
+}
+
+// matchMirrorOf returns if the mirrorOf pattern matches the repository. The pattern is a comma separated list of
+// repository ids, "*", "external:*" for repositories not on localhost or a file, "external:http:*" for those also
+// using http, and "!id" to exclude a repository.
+func matchMirrorOf(pattern, id, repoURL string) bool {
+	if pattern == wildcard || pattern == id {
+		return true
+	}
+	var match bool
+	for _, p := range strings.Split(pattern, ",") {
+		p = strings.TrimSpace(p)
+		switch {
+		case len(p) > 1 && strings.HasPrefix(p, "!"):
+			if p[1:] == id {
+				return false
+			}
+		case p == id:
+			return true
+		case p == externalWildcard && isExternal(repoURL):
+			// keep going in case the repository is excluded later
+			match = true
+		case p == externalHTTP && isExternal(repoURL) && strings.HasPrefix(strings.ToLower(repoURL), "http:"):
+			match = true
+		case p == wildcard:
+			match = true
+		}
+	}
+	return match
+}
+
+// isExternal returns if the repository is not on localhost or a file
+func isExternal(repoURL string) bool {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return u.Scheme != "file" && host != "localhost" && host != "127.0.0.1"
+}
+
+// Transport returns a copy of http.DefaultTransport that sends requests through the proxies of the settings
+func (s *Settings) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = s.ProxyURL
+	return t
+}
+
+// ProxyURL returns the URL of the proxy for the request, for use as the Proxy of a http.Transport.
+// See ProxyFor. Nil is returned if the request is not proxied.
+func (s *Settings) ProxyURL(req *http.Request) (*url.URL, error) {
+	p, ok := s.ProxyFor(req.URL)
+	if !ok {
+		return nil, nil
+	}
+	return p.URL(), nil
+}
+
+// ProxyFor returns the proxy for requests to the URL: the first active proxy for the protocol of the URL that the
+// host is not a non proxy host of. As with maven a https URL falls back to a proxy for http, but a proxy for https is
+// never used for a http URL.
+func (s *Settings) ProxyFor(u *url.URL) (Proxy, bool) {
+	if s.Proxies == nil {
+		return Proxy{}, false
+	}
+	var fallback *Proxy
+	for i, p := range *s.Proxies {
+		if !p.IsActive() || p.IsNonProxyHost(u.Hostname()) {
+			continue
+		}
+		if strings.EqualFold(p.protocol(), u.Scheme) {
+			return p, true
+		}
+		if fallback == nil && strings.EqualFold(u.Scheme, "https") && p.protocol() == "http" {
+			fallback = &(*s.Proxies)[i]
+		}
+	}
+	if fallback == nil {
+		return Proxy{}, false
+	}
+	return *fallback, true
+}
+
+func (p Proxy) protocol() string {
+	if p.Protocol == "" {
+		return "http"
+	}
+	return strings.ToLower(p.Protocol)
+}
+
+// URL returns the URL of the proxy including its credentials. The protocol of the proxy is that of the requests it
+// carries, not how it is reached, so the proxy is connected to with http unless its host gives a scheme, such as
+// https://proxy.example.com.
+func (p Proxy) URL() *url.URL {
+	port := p.Port
+	if port == 0 {
+		port = defaultProxyPort
+	}
+	scheme, host := "http", p.Host
+	if i := strings.Index(host, "://"); i > 0 {
+		scheme, host = strings.ToLower(host[:i]), strings.TrimRight(host[i+3:], "/")
+	}
+	u := &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	if p.Username != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+	return u
+}
+
+// IsNonProxyHost returns if the host is one of the proxy's nonProxyHosts, a list separated by | or , in which * is a
+// wildcard. Matching is case insensitive.
+func (p Proxy) IsNonProxyHost(host string) bool {
+	for _, h := range strings.FieldsFunc(p.NonProxyHosts, func(r rune) bool { return r == '|' || r == ',' }) {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		parts := strings.Split(h, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		re, err := regexp.Compile("(?i)^" + strings.Join(parts, ".*") + "$")
+		if err == nil && re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package settings
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchMirrorOf(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		id      string
+		url     string
+		match   bool
+	}{
+		{"*", "central", CentralURL, true},
+		{"central", "central", CentralURL, true},
+		{"central", "other", CentralURL, false},
+		{"repo1,repo2", "repo2", "https://repo2.example.com", true},
+		{"repo1, repo2", "repo3", "https://repo3.example.com", false},
+		{"*,!repo1", "repo1", "https://repo1.example.com", false},
+		{"*,!repo1", "repo2", "https://repo2.example.com", true},
+		{"!repo1,*", "repo1", "https://repo1.example.com", false},
+		{"external:*", "central", CentralURL, true},
+		{"external:*", "local", "http://localhost:8081/repo", false},
+		{"external:*", "local", "http://127.0.0.1/repo", false},
+		{"external:*", "file", "file:///tmp/repo", false},
+		{"external:*,!central", "central", CentralURL, false},
+		{"external:http:*", "insecure", "http://repo.example.com", true},
+		{"external:http:*", "central", CentralURL, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.match, matchMirrorOf(test.pattern, test.id, test.url), "%s matching %s (%s)", test.pattern, test.id, test.url)
+	}
+}
+
+func TestSettings_MirrorOf(t *testing.T) {
+	s := Settings{Mirrors: &[]Mirror{
+		{ID: "all", URL: "https://all.example.com", MirrorOf: "*,!snapshots"},
+		{ID: "exact", URL: "https://exact.example.com", MirrorOf: "releases"},
+	}}
+	m, ok := s.MirrorOf("releases", "https://releases.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "exact", m.ID, "mirror of exactly the id should be preferred")
+	m, ok = s.MirrorOf("central", CentralURL)
+	assert.True(t, ok)
+	assert.Equal(t, "all", m.ID)
+	_, ok = s.MirrorOf("snapshots", "https://snapshots.example.com")
+	assert.False(t, ok)
+}
+
+func TestProxy_IsNonProxyHost(t *testing.T) {
+	p := Proxy{Host: "proxy.example.com", NonProxyHosts: "*.internal.example.com|localhost, 10.0.*"}
+	assert.True(t, p.IsNonProxyHost("repo.internal.example.com"))
+	assert.True(t, p.IsNonProxyHost("REPO.Internal.Example.com"))
+	assert.True(t, p.IsNonProxyHost("localhost"))
+	assert.True(t, p.IsNonProxyHost("10.0.0.1"))
+	assert.False(t, p.IsNonProxyHost("internal.example.com"))
+	assert.False(t, p.IsNonProxyHost("repo.example.com"))
+}
+
+func TestSettings_ProxyFor(t *testing.T) {
+	inactive := false
+	s := Settings{Proxies: &[]Proxy{
+		{ID: "off", Active: &inactive, Host: "off.example.com"},
+		{ID: "http", Host: "http.example.com", Port: 3128, NonProxyHosts: "*.internal"},
+		{ID: "https", Protocol: "https", Host: "https.example.com", Username: "u", Password: "p", NonProxyHosts: "*.direct"},
+	}}
+	u, _ := url.Parse("https://repo.example.com/maven2")
+	p, ok := s.ProxyFor(u)
+	assert.True(t, ok)
+	assert.Equal(t, "https", p.ID, "proxy for the protocol should be preferred")
+	assert.Equal(t, "http://u:p@https.example.com:8080", p.URL().String(), "proxy should be reached with http")
+	u, _ = url.Parse("http://repo.example.com/maven2")
+	p, ok = s.ProxyFor(u)
+	assert.True(t, ok)
+	assert.Equal(t, "http", p.ID)
+	assert.Equal(t, "http://http.example.com:3128", p.URL().String())
+	u, _ = url.Parse("https://repo.direct/maven2")
+	p, ok = s.ProxyFor(u)
+	assert.True(t, ok)
+	assert.Equal(t, "http", p.ID, "https should fall back to a proxy for http")
+	u, _ = url.Parse("http://repo.internal/maven2")
+	_, ok = s.ProxyFor(u)
+	assert.False(t, ok, "http should not fall back to a proxy for https")
+
+	p = Proxy{Host: "https://tls-proxy.example.com", Port: 8443}
+	assert.Equal(t, "https://tls-proxy.example.com:8443", p.URL().String(), "scheme of the host should be kept")
+}
+
+func TestSettings_Route(t *testing.T) {
+	// a mirror requiring the credentials of its server
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != "mirror-user" || p != "mirror-pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("mirrored"))
+	}))
+	defer mirror.Close()
+	// a proxy that answers requests for other hosts itself
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+	pu, _ := url.Parse(proxy.URL)
+
+	s, err := Parse([]byte(`<settings>
+  <servers>
+    <server>
+      <id>internal</id>
+      <username>mirror-user</username>
+      <password>mirror-pass</password>
+    </server>
+  </servers>
+  <mirrors>
+    <mirror>
+      <id>internal</id>
+      <url>` + mirror.URL + `/maven2</url>
+      <mirrorOf>central</mirrorOf>
+    </mirror>
+    <mirror>
+      <id>blocked</id>
+      <url>http://blocked.example.com</url>
+      <mirrorOf>insecure</mirrorOf>
+      <blocked>true</blocked>
+    </mirror>
+  </mirrors>
+  <proxies>
+    <proxy>
+      <host>` + pu.Hostname() + `</host>
+      <port>` + pu.Port() + `</port>
+      <nonProxyHosts>127.0.0.1</nonProxyHosts>
+    </proxy>
+  </proxies>
+  <profiles>
+    <profile>
+      <id>repos</id>
+      <repositories>
+        <repository>
+          <id>other</id>
+          <url>http://repo.example.com/maven2</url>
+        </repository>
+      </repositories>
+    </profile>
+  </profiles>
+  <activeProfiles>
+    <activeProfile>repos</activeProfile>
+  </activeProfiles>
+</settings>`))
+	if err != nil {
+		t.Fatalf("error parsing settings: %v", err)
+	}
+
+	rt, err := s.Route(CentralID, "")
+	if err != nil {
+		t.Fatalf("error routing central: %v", err)
+	}
+	assert.Equal(t, "internal", rt.ID)
+	assert.Equal(t, mirror.URL+"/maven2", rt.URL)
+	if assert.NotNil(t, rt.Mirror) {
+		assert.Equal(t, "internal", rt.Mirror.ID)
+	}
+	assert.Equal(t, "mirrored", get(t, rt.Client, rt.URL+"/file"), "request to the mirror not authenticated")
+
+	rt, err = s.Route("other", "")
+	if err != nil {
+		t.Fatalf("error routing other: %v", err)
+	}
+	assert.Equal(t, "http://repo.example.com/maven2", rt.URL)
+	assert.Nil(t, rt.Mirror)
+	assert.Equal(t, "proxied", get(t, rt.Client, rt.URL+"/file"))
+	assert.Equal(t, "http://repo.example.com/maven2/file", proxied)
+
+	_, err = s.Route("insecure", "http://insecure.example.com")
+	assert.NotNil(t, err, "route to a repository with a blocked mirror should error")
+	_, err = s.Route("unknown", "")
+	if _, ok := err.(NotFound); !ok {
+		t.Errorf("expected NotFound error, got: %v", err)
+	}
+}
+
+func get(t *testing.T, cl *http.Client, u string) string {
+	resp, err := cl.Get(u)
+	if err != nil {
+		t.Fatalf("error getting %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return string(b)
+}
@@ -0,0 +1,192 @@
+package settings
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	securityFile = "settings-security.xml"
+	// masterPassphrase is the passphrase the master password in settings-security.xml is encrypted with
+	masterPassphrase = "settings.security"
+	saltSize         = 8
+	keySize          = 16
+)
+
+// Security is the maven settings-security.xml holding the master password server passwords are encrypted with
+type Security struct {
+	XMLName    xml.Name `xml:"settingsSecurity"`
+	Master     string   `xml:"master,omitempty"`
+	Relocation string   `xml:"relocation,omitempty"`
+}
+
+// DefaultSecurityPath returns the path of the user's settings security, ~/.m2/settings-security.xml
+func DefaultSecurityPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %v", err)
+	}
+	return filepath.Join(home, m2Dir, securityFile), nil
+}
+
+// LoadSecurity reads the settings security from the file at path, following a relocation to another file.
+func LoadSecurity(path string) (Security, error) {
+	var sec Security
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return sec, fmt.Errorf("could not read settings security file at %s: %v", path, err)
+	}
+	err = xml.Unmarshal(b, &sec)
+	if err != nil {
+		return sec, fmt.Errorf("could not parse settings security file at %s: %v", path, err)
+	}
+	if sec.Relocation != "" {
+		if sec.Relocation == path {
+			return sec, fmt.Errorf("settings security file at %s relocates to itself", path)
+		}
+		return LoadSecurity(sec.Relocation)
+	}
+	return sec, nil
+}
+
+// MasterPassword returns the decrypted master password
+func (s Security) MasterPassword() (string, error) {
+	if s.Master == "" {
+		return "", fmt.Errorf("no master password in settings security")
+	}
+	if !IsEncrypted(s.Master) {
+		return s.Master, nil
+	}
+	return Decrypt(s.Master, masterPassphrase)
+}
+
+// DecryptPasswords decrypts the encrypted passwords and passphrases of the servers and proxies with the master
+// password of the settings security.
+func (s *Settings) DecryptPasswords(sec Security) error {
+	var master string
+	decrypt := func(p *string) error {
+		if !IsEncrypted(*p) {
+			return nil
+		}
+		if master == "" {
+			var err error
+			master, err = sec.MasterPassword()
+			if err != nil {
+				return fmt.Errorf("could not decrypt master password: %v", err)
+			}
+		}
+		clear, err := Decrypt(*p, master)
+		if err != nil {
+			return err
+		}
+		*p = clear
+		return nil
+	}
+	if s.Servers != nil {
+		for i := range *s.Servers {
+			srv := &(*s.Servers)[i]
+			for _, p := range []*string{&srv.Password, &srv.Passphrase} {
+				if err := decrypt(p); err != nil {
+					return fmt.Errorf("could not decrypt password of server %s: %v", srv.ID, err)
+				}
+			}
+		}
+	}
+	if s.Proxies != nil {
+		for i := range *s.Proxies {
+			prx := &(*s.Proxies)[i]
+			if err := decrypt(&prx.Password); err != nil {
+				return fmt.Errorf("could not decrypt password of proxy %s: %v", prx.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// IsEncrypted returns if the string holds an encrypted value in braces, for example
+// "{COQLCE6DU6GtcS5P=}". Braces escaped with a backslash are not treated as enclosing an encrypted value.
+func IsEncrypted(s string) bool {
+	_, ok := unbrace(s)
+	return ok
+}
+
+// unbrace returns the encrypted value between the first unescaped braces of the string
+func unbrace(s string) (string, bool) {
+	start := -1
+	for i := 0; i < len(s); i++ {
+		if i > 0 && s[i-1] == '\\' {
+			continue
+		}
+		switch {
+		case s[i] == '{' && start < 0:
+			start = i
+		case s[i] == '}' && start >= 0:
+			return s[start+1 : i], true
+		}
+	}
+	return "", false
+}
+
+// Decrypt decrypts a value encrypted by maven, such as with mvn --encrypt-password, with the passphrase.
+// The value is the base64 encoding of the salt, the length of the random padding, the AES-128-CBC cipher text and the
+// padding. The key and IV are the SHA-256 digest of the passphrase and salt.
+func Decrypt(s, passphrase string) (string, error) {
+	if v, ok := unbrace(s); ok {
+		s = v
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("encrypted value is not valid base64: %v", err)
+	}
+	if len(b) < saltSize+1 {
+		return "", fmt.Errorf("encrypted value is too short")
+	}
+	salt := b[:saltSize]
+	padLen := int(b[saltSize])
+	end := len(b) - padLen
+	if end <= saltSize+1 || (end-saltSize-1)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("encrypted value is not valid")
+	}
+	ct := make([]byte, end-saltSize-1)
+	copy(ct, b[saltSize+1:end])
+
+	key, iv := deriveKey(passphrase, salt)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(ct, ct)
+	// remove PKCS#5 padding
+	n := int(ct[len(ct)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(ct[len(ct)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return "", fmt.Errorf("could not decrypt value, the passphrase may be wrong")
+	}
+	return string(ct[:len(ct)-n]), nil
+}
+
+// deriveKey derives the AES key and IV from the passphrase and salt as plexus-cipher does
+func deriveKey(passphrase string, salt []byte) (key, iv []byte) {
+	keyAndIV := make([]byte, 0, keySize+aes.BlockSize)
+	var prev []byte
+	for len(keyAndIV) < cap(keyAndIV) {
+		h := sha256.New()
+		h.Write(prev)
+		h.Write([]byte(passphrase))
+		h.Write(salt)
+		prev = h.Sum(nil)
+		n := cap(keyAndIV) - len(keyAndIV)
+		if n > len(prev) {
+			n = len(prev)
+		}
+		keyAndIV = append(keyAndIV, prev[:n]...)
+	}
+	return keyAndIV[:keySize], keyAndIV[keySize:]
+}
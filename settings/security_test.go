@@ -0,0 +1,129 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+// The vectors are those published in the tests of plexus-sec-dispatcher's DefaultSecDispatcher and plexus-cipher's
+// PBECipher, which maven encrypts passwords with.
+const (
+	testMasterPassword = "testtest"
+	testEncrypted      = "{BteqUEnqHecHM7MZfnj9FwLcYbdInWxou1C929Txa0A=}"
+	testClear          = "testtest"
+	testEncryptedText  = "{ibeHrdCOonkH7d7YnH7sarQLbwOk1ljkkM/z8hUhl4c=}"
+	testClearText      = "veryOpenText"
+)
+
+func TestDecrypt(t *testing.T) {
+	var tests = []struct {
+		encrypted  string
+		passphrase string
+		clear      string
+	}{
+		{testEncrypted, testMasterPassword, testClear},
+		{testEncryptedText, testMasterPassword, testClearText},
+		{"ibeHrdCOonkH7d7YnH7sarQLbwOk1ljkkM/z8hUhl4c=", testMasterPassword, testClearText},
+		{"rotated 2026-10-01 " + testEncryptedText, testMasterPassword, testClearText},
+		{testEncryptedText + " expires 2026-11-01", testMasterPassword, testClearText},
+		{`see \{ops\} ` + testEncryptedText, testMasterPassword, testClearText},
+		{`\{escaped} ` + testEncryptedText, testMasterPassword, testClearText},
+	}
+	for _, test := range tests {
+		clear, err := Decrypt(test.encrypted, test.passphrase)
+		if err != nil {
+			t.Errorf("error decrypting %s: %v", test.encrypted, err)
+		}
+		assert.Equal(t, test.clear, clear)
+	}
+	_, err := Decrypt(testEncrypted, "wrong")
+	assert.NotNil(t, err, "decrypting with the wrong passphrase should error")
+	_, err = Decrypt("{bm90IHZhbGlk}", testMasterPassword)
+	assert.NotNil(t, err, "decrypting an invalid value should error")
+}
+
+func TestIsEncrypted(t *testing.T) {
+	assert.True(t, IsEncrypted(testEncrypted))
+	assert.True(t, IsEncrypted("comment "+testEncrypted))
+	assert.False(t, IsEncrypted("plain"))
+	assert.False(t, IsEncrypted(`\{escaped\}`))
+	assert.False(t, IsEncrypted(`\{escaped}`))
+	assert.True(t, IsEncrypted(`\{escaped} `+testEncrypted))
+}
+
+func TestSettings_DecryptPasswords(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	relocated := filepath.Join(dir, "relocated-security.xml")
+	// no encrypted master password has been published with its clear text, so the master is given in the clear, as
+	// maven also accepts
+	err = ioutil.WriteFile(relocated, []byte(`<settingsSecurity><master>`+testMasterPassword+`</master></settingsSecurity>`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "settings-security.xml")
+	err = ioutil.WriteFile(path, []byte(`<settingsSecurity><relocation>`+relocated+`</relocation></settingsSecurity>`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec, err := LoadSecurity(path)
+	if err != nil {
+		t.Fatalf("error loading settings security: %v", err)
+	}
+	master, err := sec.MasterPassword()
+	if err != nil {
+		t.Fatalf("error decrypting master password: %v", err)
+	}
+	assert.Equal(t, testMasterPassword, master)
+
+	s, err := Parse([]byte(`<settings>
+  <servers>
+    <server>
+      <id>releases</id>
+      <username>deployer</username>
+      <password>` + testEncrypted + `</password>
+    </server>
+    <server>
+      <id>plain</id>
+      <username>user</username>
+      <password>plain</password>
+    </server>
+  </servers>
+  <proxies>
+    <proxy>
+      <id>corporate</id>
+      <host>proxy.example.com</host>
+      <password>` + testEncryptedText + `</password>
+    </proxy>
+  </proxies>
+</settings>`))
+	if err != nil {
+		t.Fatalf("error parsing settings: %v", err)
+	}
+	_, err = s.Credentials("releases")
+	assert.NotNil(t, err, "credentials with an encrypted password should error")
+
+	err = s.DecryptPasswords(sec)
+	if err != nil {
+		t.Fatalf("error decrypting passwords: %v", err)
+	}
+	a, err := s.Credentials("releases")
+	if err != nil {
+		t.Fatalf("error getting credentials: %v", err)
+	}
+	assert.Equal(t, repo.BasicAuth{Username: "deployer", Password: testClear}, a)
+	a, err = s.Credentials("plain")
+	if err != nil {
+		t.Fatalf("error getting credentials: %v", err)
+	}
+	assert.Equal(t, repo.BasicAuth{Username: "user", Password: "plain"}, a)
+	assert.Equal(t, testClearText, (*s.Proxies)[0].Password)
+}
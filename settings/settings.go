@@ -0,0 +1,261 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jcmturner/gomvn/pom"
+	"github.com/jcmturner/gomvn/repo"
+)
+
+const (
+	settingsFile    = "settings.xml"
+	m2Dir           = ".m2"
+	localRepository = "repository"
+)
+
+// Settings is the maven settings.xml
+type Settings struct {
+	XMLName         xml.Name   `xml:"settings"`
+	LocalRepository string     `xml:"localRepository,omitempty"`
+	InteractiveMode *bool      `xml:"interactiveMode,omitempty"`
+	Offline         bool       `xml:"offline,omitempty"`
+	PluginGroups    *[]string  `xml:"pluginGroups>pluginGroup,omitempty"`
+	Servers         *[]Server  `xml:"servers>server,omitempty"`
+	Mirrors         *[]Mirror  `xml:"mirrors>mirror,omitempty"`
+	Proxies         *[]Proxy   `xml:"proxies>proxy,omitempty"`
+	Profiles        *[]Profile `xml:"profiles>profile,omitempty"`
+	ActiveProfiles  *[]string  `xml:"activeProfiles>activeProfile,omitempty"`
+}
+
+// Server holds the credentials used for the repository, mirror or proxy with the same id
+type Server struct {
+	ID                   string `xml:"id"`
+	Username             string `xml:"username,omitempty"`
+	Password             string `xml:"password,omitempty"`
+	PrivateKey           string `xml:"privateKey,omitempty"`
+	Passphrase           string `xml:"passphrase,omitempty"`
+	FilePermissions      string `xml:"filePermissions,omitempty"`
+	DirectoryPermissions string `xml:"directoryPermissions,omitempty"`
+	Configuration        *Raw   `xml:"configuration,omitempty"`
+}
+
+// Mirror is a repository used in place of the repositories matching MirrorOf
+type Mirror struct {
+	ID              string `xml:"id"`
+	Name            string `xml:"name,omitempty"`
+	URL             string `xml:"url"`
+	MirrorOf        string `xml:"mirrorOf"`
+	Layout          string `xml:"layout,omitempty"`
+	MirrorOfLayouts string `xml:"mirrorOfLayouts,omitempty"`
+	Blocked         bool   `xml:"blocked,omitempty"`
+}
+
+// Proxy is a HTTP proxy used to reach repositories
+type Proxy struct {
+	ID            string `xml:"id,omitempty"`
+	Active        *bool  `xml:"active,omitempty"`
+	Protocol      string `xml:"protocol,omitempty"`
+	Host          string `xml:"host"`
+	Port          int    `xml:"port,omitempty"`
+	Username      string `xml:"username,omitempty"`
+	Password      string `xml:"password,omitempty"`
+	NonProxyHosts string `xml:"nonProxyHosts,omitempty"`
+}
+
+// Profile is a set of repositories and properties that can be activated
+type Profile struct {
+	ID                 string            `xml:"id"`
+	Activation         *Activation       `xml:"activation,omitempty"`
+	Properties         pom.Properties    `xml:"properties,omitempty"`
+	Repositories       *[]pom.Repository `xml:"repositories>repository,omitempty"`
+	PluginRepositories *[]pom.Repository `xml:"pluginRepositories>pluginRepository,omitempty"`
+}
+
+// Activation is the conditions that activate a profile
+type Activation struct {
+	ActiveByDefault bool                `xml:"activeByDefault,omitempty"`
+	JDK             string              `xml:"jdk,omitempty"`
+	OS              *ActivationOS       `xml:"os,omitempty"`
+	Property        *ActivationProperty `xml:"property,omitempty"`
+	File            *ActivationFile     `xml:"file,omitempty"`
+}
+
+type ActivationOS struct {
+	Name    string `xml:"name,omitempty"`
+	Family  string `xml:"family,omitempty"`
+	Arch    string `xml:"arch,omitempty"`
+	Version string `xml:"version,omitempty"`
+}
+
+type ActivationProperty struct {
+	Name  string `xml:"name"`
+	Value string `xml:"value,omitempty"`
+}
+
+type ActivationFile struct {
+	Missing string `xml:"missing,omitempty"`
+	Exists  string `xml:"exists,omitempty"`
+}
+
+// Raw is an element kept as its inner XML, such as the free form configuration of a server
+type Raw struct {
+	InnerXML string `xml:",innerxml"`
+}
+
+// NotFound is returned when there is no server with an id
+type NotFound struct {
+	ErrorString string
+}
+
+func (e NotFound) Error() string {
+	return e.ErrorString
+}
+
+// DefaultPath returns the path of the user's settings, ~/.m2/settings.xml
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %v", err)
+	}
+	return filepath.Join(home, m2Dir, settingsFile), nil
+}
+
+// Load reads the settings from the file at path
+func Load(path string) (Settings, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Settings{}, fmt.Errorf("could not read settings file at %s: %v", path, err)
+	}
+	s, err := Parse(b)
+	if err != nil {
+		return s, fmt.Errorf("could not parse settings file at %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses the settings XML. As with maven, ${env.X} expressions are replaced with the value of the environment
+// variable X and ${user.home} with the user's home directory before parsing.
+func Parse(b []byte) (Settings, error) {
+	var s Settings
+	decoder := xml.NewDecoder(bytes.NewReader(Interpolate(b, lookup)))
+	err := decoder.Decode(&s)
+	if err != nil {
+		return s, fmt.Errorf("error unmarshaling settings: %v", err)
+	}
+	return s, nil
+}
+
+var expression = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Interpolate replaces the ${...} expressions in the XML with the values returned by the lookup, escaped for XML.
+// Expressions the lookup does not resolve are left as they are.
+func Interpolate(b []byte, lookup func(expr string) (string, bool)) []byte {
+	return expression.ReplaceAllFunc(b, func(m []byte) []byte {
+		v, ok := lookup(string(m[2 : len(m)-1]))
+		if !ok {
+			return m
+		}
+		buf := new(bytes.Buffer)
+		xml.EscapeText(buf, []byte(v))
+		return buf.Bytes()
+	})
+}
+
+// lookup resolves environment variables and the user's home directory
+func lookup(expr string) (string, bool) {
+	if strings.HasPrefix(expr, "env.") {
+		return os.LookupEnv(strings.TrimPrefix(expr, "env."))
+	}
+	if expr == "user.home" {
+		home, err := os.UserHomeDir()
+		return home, err == nil
+	}
+	return "", false
+}
+
+// Server returns the server with the id
+func (s *Settings) Server(id string) (Server, bool) {
+	if s.Servers != nil {
+		for _, srv := range *s.Servers {
+			if srv.ID == id {
+				return srv, true
+			}
+		}
+	}
+	return Server{}, false
+}
+
+// Credentials returns the authenticator for the server with the id.
+// A NotFound error is returned if there is no such server. An encrypted password must first be decrypted with
+// DecryptPasswords.
+func (s *Settings) Credentials(id string) (repo.Authenticator, error) {
+	srv, ok := s.Server(id)
+	if !ok {
+		return nil, NotFound{
+			ErrorString: fmt.Sprintf("no server with id %s in settings", id),
+		}
+	}
+	if IsEncrypted(srv.Password) {
+		return nil, fmt.Errorf("password of server %s is encrypted", id)
+	}
+	return srv.Authenticator(), nil
+}
+
+// Authenticator returns basic authentication with the username and password of the server, or anonymous access if
+// neither is set.
+func (s Server) Authenticator() repo.Authenticator {
+	if s.Username == "" && s.Password == "" {
+		return repo.Anonymous{}
+	}
+	return repo.BasicAuth{Username: s.Username, Password: s.Password}
+}
+
+// LocalRepositoryPath returns the path of the local repository, ~/.m2/repository if not set
+func (s *Settings) LocalRepositoryPath() (string, error) {
+	if s.LocalRepository != "" {
+		return s.LocalRepository, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %v", err)
+	}
+	return filepath.Join(home, m2Dir, localRepository), nil
+}
+
+// Active returns the profiles listed in activeProfiles. If none of them are, the profiles active by default are
+// returned. Other activation conditions are not evaluated.
+func (s *Settings) Active() []Profile {
+	var active, byDefault []Profile
+	if s.Profiles == nil {
+		return active
+	}
+	for _, p := range *s.Profiles {
+		if s.ActiveProfiles != nil {
+			for _, id := range *s.ActiveProfiles {
+				if p.ID == id {
+					active = append(active, p)
+					break
+				}
+			}
+		}
+		if p.Activation != nil && p.Activation.ActiveByDefault {
+			byDefault = append(byDefault, p)
+		}
+	}
+	if len(active) == 0 {
+		return byDefault
+	}
+	return active
+}
+
+// IsActive returns if the proxy is active, which it is unless set otherwise
+func (p Proxy) IsActive() bool {
+	return p.Active == nil || *p.Active
+}
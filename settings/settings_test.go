@@ -0,0 +1,200 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcmturner/gomvn/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSettings = `<?xml version="1.0" encoding="UTF-8"?>
+<settings xmlns="http://maven.apache.org/SETTINGS/1.0.0"
+          xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+          xsi:schemaLocation="http://maven.apache.org/SETTINGS/1.0.0 https://maven.apache.org/xsd/settings-1.0.0.xsd">
+  <localRepository>${user.home}/m2repo</localRepository>
+  <offline>false</offline>
+  <servers>
+    <server>
+      <id>releases</id>
+      <username>deployer</username>
+      <password>${env.GOMVN_TEST_PASSWORD}</password>
+    </server>
+    <server>
+      <id>public</id>
+      <configuration>
+        <httpHeaders>
+          <property>
+            <name>X-Custom</name>
+            <value>value</value>
+          </property>
+        </httpHeaders>
+      </configuration>
+    </server>
+  </servers>
+  <mirrors>
+    <mirror>
+      <id>internal</id>
+      <name>Internal mirror</name>
+      <url>https://repo.example.com/maven2</url>
+      <mirrorOf>external:*,!releases</mirrorOf>
+    </mirror>
+  </mirrors>
+  <proxies>
+    <proxy>
+      <id>corporate</id>
+      <active>false</active>
+      <protocol>http</protocol>
+      <host>proxy.example.com</host>
+      <port>3128</port>
+      <nonProxyHosts>*.example.com|localhost</nonProxyHosts>
+    </proxy>
+    <proxy>
+      <host>proxy2.example.com</host>
+    </proxy>
+  </proxies>
+  <profiles>
+    <profile>
+      <id>default</id>
+      <activation>
+        <activeByDefault>true</activeByDefault>
+      </activation>
+    </profile>
+    <profile>
+      <id>internal</id>
+      <properties>
+        <deploy.url>https://repo.example.com/releases</deploy.url>
+        <undefined>${env.GOMVN_TEST_UNDEFINED}</undefined>
+      </properties>
+      <repositories>
+        <repository>
+          <id>releases</id>
+          <url>https://repo.example.com/releases</url>
+          <releases>
+            <enabled>true</enabled>
+            <checksumPolicy>fail</checksumPolicy>
+          </releases>
+        </repository>
+      </repositories>
+    </profile>
+  </profiles>
+  <activeProfiles>
+    <activeProfile>internal</activeProfile>
+  </activeProfiles>
+</settings>
+`
+
+func TestParse(t *testing.T) {
+	os.Setenv("GOMVN_TEST_PASSWORD", "p<ss&word")
+	defer os.Unsetenv("GOMVN_TEST_PASSWORD")
+	s, err := Parse([]byte(testSettings))
+	if err != nil {
+		t.Fatalf("error parsing settings: %v", err)
+	}
+	home, _ := os.UserHomeDir()
+	assert.Equal(t, home+"/m2repo", s.LocalRepository)
+
+	if !assert.NotNil(t, s.Servers) || !assert.Equal(t, 2, len(*s.Servers)) {
+		t.FailNow()
+	}
+	srv, ok := s.Server("releases")
+	assert.True(t, ok)
+	assert.Equal(t, "deployer", srv.Username)
+	assert.Equal(t, "p<ss&word", srv.Password, "environment variable not interpolated")
+	srv, ok = s.Server("public")
+	assert.True(t, ok)
+	assert.NotNil(t, srv.Configuration)
+	assert.Contains(t, srv.Configuration.InnerXML, "X-Custom")
+
+	if !assert.NotNil(t, s.Mirrors) || !assert.Equal(t, 1, len(*s.Mirrors)) {
+		t.FailNow()
+	}
+	m := (*s.Mirrors)[0]
+	assert.Equal(t, "internal", m.ID)
+	assert.Equal(t, "https://repo.example.com/maven2", m.URL)
+	assert.Equal(t, "external:*,!releases", m.MirrorOf)
+
+	if !assert.NotNil(t, s.Proxies) || !assert.Equal(t, 2, len(*s.Proxies)) {
+		t.FailNow()
+	}
+	p := (*s.Proxies)[0]
+	assert.False(t, p.IsActive())
+	assert.Equal(t, "proxy.example.com", p.Host)
+	assert.Equal(t, 3128, p.Port)
+	assert.Equal(t, "*.example.com|localhost", p.NonProxyHosts)
+	assert.True(t, (*s.Proxies)[1].IsActive(), "proxy should be active by default")
+
+	active := s.Active()
+	if !assert.Equal(t, 1, len(active)) {
+		t.FailNow()
+	}
+	assert.Equal(t, "internal", active[0].ID)
+	v, ok := active[0].Properties.Get("deploy.url")
+	assert.True(t, ok)
+	assert.Equal(t, "https://repo.example.com/releases", v)
+	v, _ = active[0].Properties.Get("undefined")
+	assert.Equal(t, "${env.GOMVN_TEST_UNDEFINED}", v, "undefined variable should be left as is")
+	if !assert.NotNil(t, active[0].Repositories) {
+		t.FailNow()
+	}
+	assert.Equal(t, "fail", (*active[0].Repositories)[0].Releases.ChecksumPolicy)
+
+	s.ActiveProfiles = nil
+	active = s.Active()
+	if assert.Equal(t, 1, len(active)) {
+		assert.Equal(t, "default", active[0].ID)
+	}
+}
+
+func TestSettings_Credentials(t *testing.T) {
+	os.Setenv("GOMVN_TEST_PASSWORD", "secret")
+	defer os.Unsetenv("GOMVN_TEST_PASSWORD")
+	s, err := Parse([]byte(testSettings))
+	if err != nil {
+		t.Fatalf("error parsing settings: %v", err)
+	}
+	a, err := s.Credentials("releases")
+	if err != nil {
+		t.Fatalf("error getting credentials: %v", err)
+	}
+	assert.Equal(t, repo.BasicAuth{Username: "deployer", Password: "secret"}, a)
+	a, err = s.Credentials("public")
+	if err != nil {
+		t.Fatalf("error getting credentials: %v", err)
+	}
+	assert.Equal(t, repo.Anonymous{}, a)
+	_, err = s.Credentials("missing")
+	if _, ok := err.(NotFound); !ok {
+		t.Errorf("expected NotFound error, got: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gomvn-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "settings.xml")
+	err = ioutil.WriteFile(path, []byte(`<settings><servers><server><id>a</id><username>u</username></server></servers></settings>`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("error loading settings: %v", err)
+	}
+	srv, ok := s.Server("a")
+	assert.True(t, ok)
+	assert.Equal(t, "u", srv.Username)
+	lr, err := s.LocalRepositoryPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "repository", filepath.Base(lr))
+
+	_, err = Load(filepath.Join(dir, "missing.xml"))
+	assert.NotNil(t, err, "loading a missing file should error")
+}
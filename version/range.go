@@ -0,0 +1,349 @@
+package version
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//Version requirements have the following syntax:
+//
+//1.0: "Soft" requirement on 1.0 (just a recommendation, if it matches all other ranges for the dependency)
+//[1.0]: "Hard" requirement on 1.0
+//(,1.0]: x <= 1.0
+//[1.2,1.3]: 1.2 <= x <= 1.3
+//[1.0,2.0): 1.0 <= x < 2.0
+//[1.5,): x >= 1.5
+//(,1.0],[1.2,): x <= 1.0 or x >= 1.2; multiple sets are comma-separated
+//(,1.1),(1.1,): this excludes 1.1 (for example if it is known not to work in combination with this library)
+
+// Invalid combinations:
+// curved brackets with only one number
+// curved bracket with same number twice
+// first number greater than 2nd
+// numbers not in brackets with comma
+// sets that overlap or are not in ascending order
+
+// Range is a maven version range. A soft requirement has a Recommended version and a single unbounded restriction.
+type Range struct {
+	Recommended  *Version
+	Restrictions []Restriction
+}
+
+// Restriction is a single set within a version range. A nil bound is unbounded.
+type Restriction struct {
+	Lower          *Version
+	LowerInclusive bool
+	Upper          *Version
+	UpperInclusive bool
+}
+
+// EmptyIntersection is returned when ranges have no versions in common.
+type EmptyIntersection struct {
+	ErrorString string
+}
+
+func (e EmptyIntersection) Error() string {
+	return e.ErrorString
+}
+
+// NoMatch is returned when no version is within a range.
+type NoMatch struct {
+	ErrorString string
+}
+
+func (e NoMatch) Error() string {
+	return e.ErrorString
+}
+
+// ParseRange parses a version requirement string into a Range
+func ParseRange(s string) (r Range, err error) {
+	spec := strings.TrimSpace(s)
+	var upper *Version
+	for strings.HasPrefix(spec, "[") || strings.HasPrefix(spec, "(") {
+		i := strings.IndexAny(spec, ")]")
+		if i < 0 {
+			err = fmt.Errorf("invalid version requirements %s: unbounded range", s)
+			return
+		}
+		var res Restriction
+		res, err = parseRestriction(spec[:i+1])
+		if err != nil {
+			err = fmt.Errorf("invalid version requirements %s: %v", s, err)
+			return
+		}
+		if len(r.Restrictions) > 0 && (res.Lower == nil || upper == nil || res.Lower.Less(*upper)) {
+			err = fmt.Errorf("invalid version requirements %s: ranges overlap", s)
+			return
+		}
+		r.Restrictions = append(r.Restrictions, res)
+		upper = res.Upper
+		spec = strings.TrimSpace(spec[i+1:])
+		if strings.HasPrefix(spec, ",") {
+			spec = strings.TrimSpace(spec[1:])
+		}
+	}
+	if spec != "" {
+		if len(r.Restrictions) > 0 {
+			err = fmt.Errorf("invalid version requirements %s: only fully-qualified sets allowed in multiple set scenario", s)
+			return
+		}
+		if strings.ContainsAny(spec, "[](),") {
+			err = fmt.Errorf("invalid version requirements %s", s)
+			return
+		}
+		var v Version
+		v, err = New(spec)
+		if err != nil {
+			err = fmt.Errorf("could not parse version condition %s: %v", s, err)
+			return
+		}
+		r.Recommended = &v
+		r.Restrictions = []Restriction{{}}
+	}
+	if len(r.Restrictions) == 0 {
+		err = fmt.Errorf("invalid version requirements %s: no version or range", s)
+	}
+	return
+}
+
+func parseRestriction(s string) (res Restriction, err error) {
+	res.LowerInclusive = strings.HasPrefix(s, "[")
+	res.UpperInclusive = strings.HasSuffix(s, "]")
+	spec := strings.TrimSpace(s[1 : len(s)-1])
+	bounds := strings.Split(spec, ",")
+	switch len(bounds) {
+	case 1:
+		if !res.LowerInclusive || !res.UpperInclusive {
+			err = fmt.Errorf("single version must be surrounded by []: %s", s)
+			return
+		}
+		var v Version
+		v, err = New(spec)
+		if err != nil {
+			err = fmt.Errorf("could not parse version %s: %v", spec, err)
+			return
+		}
+		res.Lower = &v
+		res.Upper = &v
+	case 2:
+		l := strings.TrimSpace(bounds[0])
+		u := strings.TrimSpace(bounds[1])
+		if l == u {
+			err = fmt.Errorf("range cannot have identical boundaries: %s", s)
+			return
+		}
+		if l != "" {
+			var v Version
+			v, err = New(l)
+			if err != nil {
+				err = fmt.Errorf("could not parse lower bound version %s: %v", l, err)
+				return
+			}
+			res.Lower = &v
+		}
+		if u != "" {
+			var v Version
+			v, err = New(u)
+			if err != nil {
+				err = fmt.Errorf("could not parse upper bound version %s: %v", u, err)
+				return
+			}
+			res.Upper = &v
+		}
+		if res.Lower != nil && res.Upper != nil && res.Upper.Less(*res.Lower) {
+			err = fmt.Errorf("range defies version ordering: %s", s)
+			return
+		}
+	default:
+		err = fmt.Errorf("too many bounds in range: %s", s)
+	}
+	return
+}
+
+// IsSoft indicates if the range carries a recommended version rather than only hard restrictions.
+func (r Range) IsSoft() bool {
+	return r.Recommended != nil
+}
+
+// Contains indicates if the version is within the range.
+// A soft requirement is unrestricted so contains any version.
+func (r Range) Contains(v Version) bool {
+	for _, res := range r.Restrictions {
+		if res.Contains(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains indicates if the version is within the restriction
+func (res Restriction) Contains(v Version) bool {
+	if res.Lower != nil {
+		c := res.Lower.Compare(v)
+		if c > 0 || (c == 0 && !res.LowerInclusive) {
+			return false
+		}
+	}
+	if res.Upper != nil {
+		c := res.Upper.Compare(v)
+		if c < 0 || (c == 0 && !res.UpperInclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersect returns the range of versions within both ranges.
+// The recommended version of r is kept if it is within the result, otherwise that of o if it is.
+// An EmptyIntersection error is returned if the ranges have no versions in common.
+func (r Range) Intersect(o Range) (Range, error) {
+	var i Range
+	for _, a := range r.Restrictions {
+		for _, b := range o.Restrictions {
+			if res, ok := a.intersect(b); ok {
+				i.Restrictions = append(i.Restrictions, res)
+			}
+		}
+	}
+	if len(i.Restrictions) == 0 {
+		return i, EmptyIntersection{
+			ErrorString: fmt.Sprintf("no versions within both %s and %s", r.String(), o.String()),
+		}
+	}
+	sort.Sort(restrictions(i.Restrictions))
+	if r.Recommended != nil && i.Contains(*r.Recommended) {
+		i.Recommended = r.Recommended
+	} else if o.Recommended != nil && i.Contains(*o.Recommended) {
+		i.Recommended = o.Recommended
+	}
+	return i, nil
+}
+
+// Union returns the range of versions within either range. Overlapping sets are merged.
+// The recommended version of r is kept, otherwise that of o.
+func (r Range) Union(o Range) Range {
+	var u Range
+	u.Recommended = r.Recommended
+	if u.Recommended == nil {
+		u.Recommended = o.Recommended
+	}
+	all := make([]Restriction, 0, len(r.Restrictions)+len(o.Restrictions))
+	all = append(all, r.Restrictions...)
+	all = append(all, o.Restrictions...)
+	sort.Sort(restrictions(all))
+	for _, res := range all {
+		n := len(u.Restrictions)
+		if n > 0 && u.Restrictions[n-1].joins(res) {
+			last := &u.Restrictions[n-1]
+			if last.Upper != nil && (res.Upper == nil || last.Upper.Less(*res.Upper)) {
+				last.Upper = res.Upper
+				last.UpperInclusive = res.UpperInclusive
+			} else if last.Upper != nil && last.Upper.Equal(*res.Upper) {
+				last.UpperInclusive = last.UpperInclusive || res.UpperInclusive
+			}
+			continue
+		}
+		u.Restrictions = append(u.Restrictions, res)
+	}
+	return u
+}
+
+// String returns the range in maven's version requirement syntax
+func (r Range) String() string {
+	if r.Recommended != nil {
+		return r.Recommended.String()
+	}
+	s := make([]string, len(r.Restrictions))
+	for i, res := range r.Restrictions {
+		s[i] = res.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// String returns the restriction in maven's version requirement syntax
+func (res Restriction) String() string {
+	if res.Lower != nil && res.Upper != nil && res.LowerInclusive && res.UpperInclusive && res.Lower.Equal(*res.Upper) {
+		return "[" + res.Lower.String() + "]"
+	}
+	var b strings.Builder
+	if res.LowerInclusive {
+		b.WriteByte('[')
+	} else {
+		b.WriteByte('(')
+	}
+	if res.Lower != nil {
+		b.WriteString(res.Lower.String())
+	}
+	b.WriteByte(',')
+	if res.Upper != nil {
+		b.WriteString(res.Upper.String())
+	}
+	if res.UpperInclusive {
+		b.WriteByte(']')
+	} else {
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// intersect returns the overlap of two restrictions and whether there is one
+func (res Restriction) intersect(o Restriction) (Restriction, bool) {
+	i := res
+	if o.Lower != nil {
+		if i.Lower == nil {
+			i.Lower, i.LowerInclusive = o.Lower, o.LowerInclusive
+		} else if c := i.Lower.Compare(*o.Lower); c < 0 {
+			i.Lower, i.LowerInclusive = o.Lower, o.LowerInclusive
+		} else if c == 0 {
+			i.LowerInclusive = i.LowerInclusive && o.LowerInclusive
+		}
+	}
+	if o.Upper != nil {
+		if i.Upper == nil {
+			i.Upper, i.UpperInclusive = o.Upper, o.UpperInclusive
+		} else if c := i.Upper.Compare(*o.Upper); c > 0 {
+			i.Upper, i.UpperInclusive = o.Upper, o.UpperInclusive
+		} else if c == 0 {
+			i.UpperInclusive = i.UpperInclusive && o.UpperInclusive
+		}
+	}
+	if i.Lower != nil && i.Upper != nil {
+		c := i.Lower.Compare(*i.Upper)
+		if c > 0 || (c == 0 && !(i.LowerInclusive && i.UpperInclusive)) {
+			return i, false
+		}
+	}
+	return i, true
+}
+
+// joins indicates if the restriction o, which starts at or after res, overlaps or abuts res with no gap
+func (res Restriction) joins(o Restriction) bool {
+	if res.Upper == nil || o.Lower == nil {
+		return true
+	}
+	c := res.Upper.Compare(*o.Lower)
+	return c > 0 || (c == 0 && (res.UpperInclusive || o.LowerInclusive))
+}
+
+// restrictions sorts by lower bound, unbounded first.
+type restrictions []Restriction
+
+func (r restrictions) Len() int {
+	return len(r)
+}
+
+func (r restrictions) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+func (r restrictions) Less(i, j int) bool {
+	if r[i].Lower == nil || r[j].Lower == nil {
+		return r[i].Lower == nil && r[j].Lower != nil
+	}
+	c := r[i].Lower.Compare(*r[j].Lower)
+	if c == 0 {
+		return r[i].LowerInclusive && !r[j].LowerInclusive
+	}
+	return c < 0
+}
@@ -0,0 +1,166 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		req string
+	}{
+		//1.0: "Soft" requirement on 1.0 (just a recommendation, if it matches all other ranges for the dependency)
+		//[1.0]: "Hard" requirement on 1.0
+		//(,1.0]: x <= 1.0
+		//[1.2,1.3]: 1.2 <= x <= 1.3
+		//[1.0,2.0): 1.0 <= x < 2.0
+		//[1.5,): x >= 1.5
+		//(,1.0],[1.2,): x <= 1.0 or x >= 1.2; multiple sets are comma-separated
+		//(,1.1),(1.1,)
+		{"1.0"},
+		{"[1.0]"},
+		{"(,1.0]"},
+		{"[1.2,1.3]"},
+		{"[1.0,2.0)"},
+		{"[1.5,)"},
+		{"(,1.0],[1.2,)"},
+		{"(,1.1),(1.1,)"},
+	}
+	for _, test := range tests {
+		r, err := ParseRange(test.req)
+		if err != nil {
+			t.Errorf("could not parse requirement %s: %v", test.req, err)
+		}
+		assert.True(t, len(r.Restrictions) > 0)
+		assert.Equal(t, test.req, r.String())
+	}
+}
+
+func TestParseRange_Invalid(t *testing.T) {
+	tests := []string{
+		"(1.0)",
+		"[1.0)",
+		"(1.0]",
+		"(1.0,1.0]",
+		"[1.0,1.0)",
+		"(1.0,1.0)",
+		"[1.1,1.0]",
+		"[1.0,1.2),1.3",
+		"",
+		"1.0,1.2",
+		"[1.0,1.2",
+		"[1.0,1.2,1.3]",
+		// overlap
+		"[1.0,1.2),(1.1,1.3]",
+		// overlap
+		"[1.1,1.3),(1.0,1.2]",
+		// ordering
+		"(1.1,1.2],[1.0,1.1)",
+	}
+	for _, test := range tests {
+		_, err := ParseRange(test)
+		assert.NotNil(t, err, "did not error on invalid requirement: %s", test)
+	}
+}
+
+func TestRange_IsSoft(t *testing.T) {
+	r, err := ParseRange("1.0")
+	if err != nil {
+		t.Fatalf("could not parse range: %v", err)
+	}
+	assert.True(t, r.IsSoft())
+	assert.Equal(t, "1.0", r.Recommended.String())
+	// a soft requirement is only a recommendation so any version is within it
+	for _, s := range []string{"0.1", "1.0", "2.0"} {
+		v, _ := New(s)
+		assert.True(t, r.Contains(v), "soft range should contain %s", s)
+	}
+	r, err = ParseRange("[1.0]")
+	if err != nil {
+		t.Fatalf("could not parse range: %v", err)
+	}
+	assert.False(t, r.IsSoft())
+}
+
+func TestRange_Intersect(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected string
+	}{
+		{"1.0", "1.1", "1.0"},
+		{"1.0", "[1.0,2.0)", "1.0"},
+		{"1.0", "[1.1,2.0)", "[1.1,2.0)"},
+		{"[1.1,2.0)", "1.5", "1.5"},
+		{"[1.0,2.0)", "[1.5,3.0)", "[1.5,2.0)"},
+		{"[1.0,2.0]", "[2.0,3.0)", "[2.0]"},
+		{"(,1.0],[1.2,)", "[0.5,1.5]", "[0.5,1.0],[1.2,1.5]"},
+		{"(,1.1),(1.1,)", "[1.0,2.0]", "[1.0,1.1),(1.1,2.0]"},
+		{"[1.0,)", "(,1.5)", "[1.0,1.5)"},
+		{"(1.0,2.0)", "[1.0,2.0]", "(1.0,2.0)"},
+	}
+	for _, test := range tests {
+		a, err := ParseRange(test.a)
+		if err != nil {
+			t.Fatalf("could not parse range %s: %v", test.a, err)
+		}
+		b, err := ParseRange(test.b)
+		if err != nil {
+			t.Fatalf("could not parse range %s: %v", test.b, err)
+		}
+		i, err := a.Intersect(b)
+		if err != nil {
+			t.Errorf("error intersecting %s and %s: %v", test.a, test.b, err)
+			continue
+		}
+		assert.Equal(t, test.expected, i.String(), "intersection of %s and %s", test.a, test.b)
+	}
+}
+
+func TestRange_Intersect_Empty(t *testing.T) {
+	tests := []struct {
+		a string
+		b string
+	}{
+		{"[1.0,2.0)", "[2.0,3.0)"},
+		{"[1.0]", "[1.1]"},
+		{"(,1.0)", "(1.0,)"},
+		{"(,1.0],[1.2,)", "(1.0,1.2)"},
+	}
+	for _, test := range tests {
+		a, _ := ParseRange(test.a)
+		b, _ := ParseRange(test.b)
+		_, err := a.Intersect(b)
+		if _, ok := err.(EmptyIntersection); !ok {
+			t.Errorf("expected EmptyIntersection error intersecting %s and %s, got: %v", test.a, test.b, err)
+		}
+	}
+}
+
+func TestRange_Union(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected string
+	}{
+		{"[1.0,2.0)", "[1.5,3.0)", "[1.0,3.0)"},
+		{"[1.0,2.0)", "[2.0,3.0)", "[1.0,3.0)"},
+		{"[1.0,2.0)", "(2.0,3.0)", "[1.0,2.0),(2.0,3.0)"},
+		{"[2.0,3.0)", "[1.0]", "[1.0],[2.0,3.0)"},
+		{"(,1.0]", "[0.5,)", "(,)"},
+		{"[1.0,2.0]", "(1.5,2.0)", "[1.0,2.0]"},
+		{"(,1.1),(1.1,)", "[1.1]", "(,)"},
+	}
+	for _, test := range tests {
+		a, err := ParseRange(test.a)
+		if err != nil {
+			t.Fatalf("could not parse range %s: %v", test.a, err)
+		}
+		b, err := ParseRange(test.b)
+		if err != nil {
+			t.Fatalf("could not parse range %s: %v", test.b, err)
+		}
+		assert.Equal(t, test.expected, a.Union(b).String(), "union of %s and %s", test.a, test.b)
+	}
+}
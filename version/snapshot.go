@@ -0,0 +1,106 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Snapshot is the qualifier of a SNAPSHOT version
+	Snapshot = "SNAPSHOT"
+	// SnapshotTimestampLayout is the layout of the timestamp in a timestamped snapshot version
+	SnapshotTimestampLayout = "20060102.150405"
+)
+
+// timestampedPattern matches a timestamped snapshot version such as 1.0-20201012.101112-3
+var timestampedPattern = regexp.MustCompile(`^(.*)-([0-9]{8}\.[0-9]{6})-([0-9]+)$`)
+
+// Timestamped is a timestamped snapshot version as deployed to a repository. eg 1.0-20201012.101112-3
+type Timestamped struct {
+	BaseVersion Version
+	Timestamp   time.Time
+	BuildNumber int
+}
+
+// IsSnapshot indicates if the version is a SNAPSHOT, either as 1.0-SNAPSHOT or in timestamped form.
+// As with maven the SNAPSHOT qualifier is not case sensitive.
+func (v Version) IsSnapshot() bool {
+	return isSnapshotBase(v.value) || timestampedPattern.MatchString(v.value)
+}
+
+// isSnapshotBase indicates if the version string ends with the -SNAPSHOT qualifier, in any case
+func isSnapshotBase(s string) bool {
+	return strings.HasSuffix(strings.ToUpper(s), "-"+Snapshot)
+}
+
+// BaseVersion returns the -SNAPSHOT form of a timestamped snapshot version.
+// Any other version is returned unchanged.
+func (v Version) BaseVersion() Version {
+	m := timestampedPattern.FindStringSubmatch(v.value)
+	if m == nil {
+		return v
+	}
+	b, _ := New(m[1] + "-" + Snapshot)
+	return b
+}
+
+// NewTimestamped creates a timestamped snapshot from the base version, which must be a -SNAPSHOT version.
+func NewTimestamped(base Version, t time.Time, buildNumber int) (Timestamped, error) {
+	if !isSnapshotBase(base.value) {
+		return Timestamped{}, fmt.Errorf("version %s is not a %s version", base.value, Snapshot)
+	}
+	return Timestamped{
+		BaseVersion: base,
+		Timestamp:   t.UTC(),
+		BuildNumber: buildNumber,
+	}, nil
+}
+
+// ParseTimestamped parses a timestamped snapshot version string such as 1.0-20201012.101112-3
+func ParseTimestamped(s string) (ts Timestamped, err error) {
+	m := timestampedPattern.FindStringSubmatch(s)
+	if m == nil {
+		err = fmt.Errorf("%s is not a timestamped snapshot version", s)
+		return
+	}
+	ts.BaseVersion, err = New(m[1] + "-" + Snapshot)
+	if err != nil {
+		return
+	}
+	ts.Timestamp, err = time.Parse(SnapshotTimestampLayout, m[2])
+	if err != nil {
+		err = fmt.Errorf("invalid timestamp in snapshot version %s: %v", s, err)
+		return
+	}
+	ts.BuildNumber, err = strconv.Atoi(m[3])
+	if err != nil {
+		err = fmt.Errorf("invalid build number in snapshot version %s: %v", s, err)
+	}
+	return
+}
+
+// String returns the timestamped form of the version. eg 1.0-20201012.101112-3
+func (ts Timestamped) String() string {
+	return fmt.Sprintf("%s-%s", ts.prefix(), ts.Qualifier())
+}
+
+// Qualifier returns the timestamp and build number that replace SNAPSHOT. eg 20201012.101112-3
+func (ts Timestamped) Qualifier() string {
+	return fmt.Sprintf("%s-%d", ts.Timestamp.UTC().Format(SnapshotTimestampLayout), ts.BuildNumber)
+}
+
+// Version returns the timestamped snapshot as a Version
+func (ts Timestamped) Version() Version {
+	v, _ := New(ts.String())
+	return v
+}
+
+func (ts Timestamped) prefix() string {
+	if !isSnapshotBase(ts.BaseVersion.value) {
+		return ts.BaseVersion.value
+	}
+	return ts.BaseVersion.value[:len(ts.BaseVersion.value)-len("-"+Snapshot)]
+}
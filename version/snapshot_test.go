@@ -0,0 +1,92 @@
+package version
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersion_IsSnapshot(t *testing.T) {
+	tests := []struct {
+		v        string
+		snapshot bool
+	}{
+		{"1.0-SNAPSHOT", true},
+		{"1.0-alpha-1-SNAPSHOT", true},
+		{"1.0-snapshot", true},
+		{"1.0-Snapshot", true},
+		{"1.0-20201012.101112-3", true},
+		{"1.0-beta-20201012.101112-3", true},
+		{"1.0", false},
+		{"1.0-alpha-1", false},
+		{"1.0-20201012", false},
+		{"1.0-20201012.1011-3", false},
+	}
+	for _, test := range tests {
+		v, err := New(test.v)
+		if err != nil {
+			t.Fatalf("could not create version from %s: %v", test.v, err)
+		}
+		assert.Equal(t, test.snapshot, v.IsSnapshot(), "is %s a snapshot", test.v)
+	}
+}
+
+func TestVersion_BaseVersion(t *testing.T) {
+	tests := []struct {
+		v    string
+		base string
+	}{
+		{"1.0-SNAPSHOT", "1.0-SNAPSHOT"},
+		{"1.0-20201012.101112-3", "1.0-SNAPSHOT"},
+		{"1.0-beta-20201012.101112-3", "1.0-beta-SNAPSHOT"},
+		{"1.0", "1.0"},
+	}
+	for _, test := range tests {
+		v, err := New(test.v)
+		if err != nil {
+			t.Fatalf("could not create version from %s: %v", test.v, err)
+		}
+		b := v.BaseVersion()
+		assert.Equal(t, test.base, b.String(), "base version of %s", test.v)
+	}
+}
+
+func TestParseTimestamped(t *testing.T) {
+	ts, err := ParseTimestamped("1.0-20201012.101112-3")
+	if err != nil {
+		t.Fatalf("could not parse timestamped snapshot: %v", err)
+	}
+	assert.Equal(t, "1.0-SNAPSHOT", ts.BaseVersion.String())
+	assert.Equal(t, time.Date(2020, 10, 12, 10, 11, 12, 0, time.UTC), ts.Timestamp)
+	assert.Equal(t, 3, ts.BuildNumber)
+	assert.Equal(t, "20201012.101112-3", ts.Qualifier())
+	assert.Equal(t, "1.0-20201012.101112-3", ts.String())
+	v := ts.Version()
+	assert.True(t, v.IsSnapshot())
+
+	for _, s := range []string{"1.0-SNAPSHOT", "1.0", "1.0-20201312.101112-3"} {
+		_, err := ParseTimestamped(s)
+		assert.NotNil(t, err, "parsing %s should error", s)
+	}
+}
+
+func TestNewTimestamped(t *testing.T) {
+	base, _ := New("2.1-SNAPSHOT")
+	ts, err := NewTimestamped(base, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), 7)
+	if err != nil {
+		t.Fatalf("could not create timestamped snapshot: %v", err)
+	}
+	assert.Equal(t, "2.1-20261016.120000-7", ts.String())
+
+	lower, _ := New("2.1-snapshot")
+	ts, err = NewTimestamped(lower, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), 7)
+	if err != nil {
+		t.Fatalf("could not create timestamped snapshot of a lower case SNAPSHOT: %v", err)
+	}
+	assert.Equal(t, "2.1-20261016.120000-7", ts.String())
+
+	release, _ := New("2.1")
+	_, err = NewTimestamped(release, time.Now(), 1)
+	assert.NotNil(t, err, "a release version cannot be timestamped")
+}
@@ -4,328 +4,332 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
 	"strings"
 )
 
 // Documentation reference: https://maven.apache.org/pom.html
+// Comparison follows the algorithm of maven's ComparableVersion:
+// https://maven.apache.org/ref/3.8.6/maven-artifact/apidocs/org/apache/maven/artifact/versioning/ComparableVersion.html
 
 // Version implements methods to handle versions as defined by maven
 type Version struct {
-	major      int
-	fields     []vfield
-	normalised string
-}
-
-type vfield struct {
-	dot          bool
-	numeric      bool
-	value        string
-	numericValue int
+	value     string
+	items     listItem
+	canonical string
 }
 
 // New creates a maven Version from a version string
 func New(s string) (v Version, err error) {
-	s = normaliseVersion(s)
-	v.normalised = s
-	i := strings.IndexAny(s, "-.")
-	if i == -1 {
-		// there are no separators for fields
-		v.major, err = strconv.Atoi(s)
-		if err != nil {
-			err = fmt.Errorf("invalid version string. major value is not a number: %v", err)
-		}
+	if strings.TrimSpace(s) == "" {
+		err = errors.New("invalid version string. version is empty")
 		return
 	}
-	var d bool
-	if string(s[i]) == "." {
-		d = true
-	}
-	v.major, err = strconv.Atoi(s[:i])
-	if err != nil {
-		err = fmt.Errorf("invalid version string. major value is not a number: %v", err)
-	}
-	s = s[i+1:]
-	for {
-		i := strings.IndexAny(s, "-.")
-		if i < 0 {
-			var numeric bool
-			n, err := strconv.Atoi(s)
-			if err == nil {
-				numeric = true
-			}
-			v.fields = append(v.fields, vfield{dot: d, numeric: numeric, value: s, numericValue: n})
-			break
-		}
-		var numeric bool
-		n, err := strconv.Atoi(s[:i])
-		if err == nil {
-			numeric = true
-		}
-		v.fields = append(v.fields, vfield{dot: d, numeric: numeric, value: s[:i], numericValue: n})
-		d = false
-		if string(s[i]) == "." {
-			d = true
-		}
-		s = s[i+1:]
-	}
-	v.fields = trimVersionFields(v.fields)
+	v.value = s
+	v.items = parseItems(s)
+	v.canonical = v.items.String()
 	return
 }
 
-func normaliseVersion(v string) string {
-	dots := strings.Split(v, ".")
-	for i := range dots {
-		//Add zero to trailing hyphens
-		if strings.HasSuffix(dots[i], "-") {
-			dots[i] = dots[i] + "0"
-		}
-		if dots[i] == "" {
-			dots[i] = "0"
-		}
-		//Ensure there are hypens between letters and numbers
-		dots[i] = hyphenateAlphaNumeric(dots[i])
-	}
-	return strings.Join(dots, ".")
-}
-
-func hyphenateAlphaNumeric(s string) string {
-	re := regexp.MustCompile("[a-zA-Z][0-9]")
-	ns := re.FindAllStringIndex(s, -1)
-	if len(ns) != 0 {
-		var p []string
-		var l int
-		for _, f := range ns {
-			p = append(p, s[l:f[0]+1])
-			l = f[0] + 1
+// parseItems splits the version string into a tree of items.
+// A '.' separates items within the same list, a '-' or a transition between digits and letters starts a new sub list.
+func parseItems(s string) listItem {
+	s = strings.ToLower(s)
+	root := new(listItem)
+	list := root
+	stack := []*listItem{root}
+	push := func() {
+		l := new(listItem)
+		*list = append(*list, l)
+		list = l
+		stack = append(stack, l)
+	}
+	var isDigit bool
+	var start int
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.':
+			if i == start {
+				*list = append(*list, intItem("0"))
+			} else {
+				*list = append(*list, parseItem(isDigit, s[start:i]))
+			}
+			start = i + 1
+		case c == '-':
+			if i == start {
+				*list = append(*list, intItem("0"))
+			} else {
+				*list = append(*list, parseItem(isDigit, s[start:i]))
+			}
+			start = i + 1
+			push()
+		case c >= '0' && c <= '9':
+			if !isDigit && i > start {
+				*list = append(*list, newStringItem(s[start:i], true))
+				start = i
+				push()
+			}
+			isDigit = true
+		default:
+			if isDigit && i > start {
+				*list = append(*list, parseItem(true, s[start:i]))
+				start = i
+				push()
+			}
+			isDigit = false
 		}
-		p = append(p, s[l:])
-		s = strings.Join(p, "-")
 	}
-	re = regexp.MustCompile("[0-9][a-zA-Z]")
-	ns = re.FindAllStringIndex(s, -1)
-	if len(ns) == 0 {
-		return s
+	if len(s) > start {
+		*list = append(*list, parseItem(isDigit, s[start:]))
 	}
-	var p []string
-	var l int
-	for _, f := range ns {
-		p = append(p, s[l:f[0]+1])
-		l = f[0] + 1
+	// normalise the lists from the deepest up so that emptied sub lists are removed from their parents
+	for i := len(stack) - 1; i >= 0; i-- {
+		stack[i].normalise()
 	}
-	p = append(p, s[l:])
-	return strings.Join(p, "-")
-}
-
-// trimeVersionFields ranges in reverse over the field removing null fields until non-null fields are met.
-func trimVersionFields(f []vfield) []vfield {
-	sf := splitOnHypen(f)
-	var rf []vfield
-	for _, vf := range sf {
-		for i := len(vf) - 1; i >= 0; i-- {
-			v := trimNullSuffix(vf[i].value)
-			if v == "" {
-				vf = vf[:len(vf)-1]
-			} else {
-				break
-			}
+	return root.resolve()
+}
+
+func parseItem(isDigit bool, s string) item {
+	if isDigit {
+		s = strings.TrimLeft(s, "0")
+		if s == "" {
+			s = "0"
 		}
-		rf = append(rf, vf...)
+		return intItem(s)
 	}
-	return rf
+	return newStringItem(s, false)
+}
+
+// item is an element of the version tree. The item passed to compare may be nil which represents padding of the
+// shorter version.
+type item interface {
+	compare(item) int
+	isNull() bool
+	String() string
 }
 
-func splitOnHypen(f []vfield) [][]vfield {
-	var sf [][]vfield
-	var j int
-	for i, v := range f {
-		if !v.dot {
-			sf = append(sf, f[j:i])
-			j = i
+// intItem is a numeric item of arbitrary size held as its decimal digits without leading zeros.
+type intItem string
+
+func (i intItem) isNull() bool {
+	return i == "0"
+}
+
+func (i intItem) compare(o item) int {
+	switch o := o.(type) {
+	case nil:
+		if i.isNull() {
+			// 1.0 == 1
+			return 0
 		}
+		// 1.1 > 1
+		return 1
+	case intItem:
+		if len(i) != len(o) {
+			if len(i) < len(o) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(string(i), string(o))
+	case stringItem:
+		// 1.1 > 1-sp
+		return 1
+	default:
+		// 1.1 > 1-1
+		return 1
 	}
-	if j < len(f) {
-		sf = append(sf, f[j:])
-	}
-	return sf
 }
 
-func trimNullSuffix(s string) string {
-	//trailing "null" values (0, "", "final", "ga") are trimmed
-	s = strings.TrimSuffix(s, "0")
-	s = strings.TrimSuffix(s, "final")
-	s = strings.TrimSuffix(s, "ga")
-	s = strings.TrimSuffix(s, "-")
-	return strings.TrimSuffix(s, ".")
+func (i intItem) String() string {
+	return string(i)
 }
 
-// String returns a normalised version string
-func (v *Version) String() string {
-	return v.normalised
+// qualifiers are the well known qualifiers in order. Any other qualifier sorts after these lexically.
+var qualifiers = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+
+var qualifierAliases = map[string]string{
+	"ga":      "",
+	"final":   "",
+	"release": "",
+	"cr":      "rc",
 }
 
-// TrimmedString returns the version in a trimmed format.
-// See "Trimmed Examples" at https://maven.apache.org/pom.html
-func (v *Version) TrimmedString() string {
-	s := strconv.Itoa(v.major)
-	for _, f := range v.fields {
-		sep := "-"
-		if f.dot {
-			sep = "."
+// releaseQualifier is the comparable form of the release qualifier ("")
+const releaseQualifier = "5"
+
+// stringItem is a qualifier item.
+type stringItem string
+
+func newStringItem(s string, followedByDigit bool) stringItem {
+	if followedByDigit && len(s) == 1 {
+		// a1 = alpha-1, b1 = beta-1, m1 = milestone-1
+		switch s {
+		case "a":
+			s = "alpha"
+		case "b":
+			s = "beta"
+		case "m":
+			s = "milestone"
 		}
-		s = s + sep + f.value
 	}
-	if s == "0" {
-		s = ""
+	if a, ok := qualifierAliases[s]; ok {
+		s = a
 	}
-	return s
+	return stringItem(s)
 }
 
-// Less indicates if the Version v is less than the Version w
-func (v Version) Less(w Version) bool {
-	if v.major != w.major {
-		return v.major < w.major
-	}
-	ip, jp := padForComparison(v, w)
-	for fi := range ip.fields {
-		if ip.fields[fi].dot == jp.fields[fi].dot {
-			// older -> newer
-			// "alpha" = "a" < "beta" = "b" < "milestone" = "m" < "rc" = "cr" < "snapshot" < "" = "final" = "ga" < "sp" < [A-Z] < [1-9]
-			if ip.fields[fi].numeric && jp.fields[fi].numeric {
-				if ip.fields[fi].numericValue == jp.fields[fi].numericValue {
-					continue
-				}
-				return ip.fields[fi].numericValue < jp.fields[fi].numericValue
-			}
-			if ip.fields[fi].numeric && !jp.fields[fi].numeric {
-				return false
-			}
-			if !ip.fields[fi].numeric && jp.fields[fi].numeric {
-				return true
-			}
-			// both are qualifiers not numbers
-			t := map[string]string{
-				"alpha":     "1",
-				"a":         "1",
-				"beta":      "2",
-				"b":         "2",
-				"milestone": "3",
-				"m":         "3",
-				"rc":        "4",
-				"cr":        "4",
-				"snapshot":  "5",
-				"":          "6",
-				"final":     "6",
-				"ga":        "6",
-				"sp":        "7",
-			}
-			ip.fields[fi].value = strings.ToLower(ip.fields[fi].value)
-			ip.fields[fi].value = strings.ToLower(ip.fields[fi].value)
-			if r, ok := t[ip.fields[fi].value]; ok {
-				ip.fields[fi].value = r
-			}
-			if r, ok := t[jp.fields[fi].value]; ok {
-				jp.fields[fi].value = r
-			}
-			x := strings.Compare(ip.fields[fi].value, jp.fields[fi].value)
-			if x == 0 {
-				continue
-			}
-			if x == -1 {
-				return true
-			}
-			return false
-		} else {
-			// ".qualifier" < "-qualifier" < "-number" < ".number"
-			if !ip.fields[fi].numeric && jp.fields[fi].numeric {
-				// qualifier always less than qualifier
-				return true
-			}
-			if ip.fields[fi].numeric && jp.fields[fi].numeric {
-				// both are numeric: - < .
-				return !ip.fields[fi].dot
-			} else {
-				// both are qualifier: . < -
-				return ip.fields[fi].dot
-			}
+// comparable returns a string that can be compared lexically to order the qualifier:
+// "alpha" < "beta" < "milestone" < "rc" = "cr" < "snapshot" < "" = "final" = "ga" = "release" < "sp" < others
+func (s stringItem) comparable() string {
+	for i, q := range qualifiers {
+		if string(s) == q {
+			return fmt.Sprint(i)
 		}
 	}
-	return false
+	return fmt.Sprintf("%d-%s", len(qualifiers), s)
 }
 
-// Equal evaluates if the version provided is equal to this version
-func (v *Version) Equal(w Version) bool {
-	if v.major != w.major {
-		return false
+func (s stringItem) isNull() bool {
+	return s.comparable() == releaseQualifier
+}
+
+func (s stringItem) compare(o item) int {
+	switch o := o.(type) {
+	case nil:
+		// 1-rc < 1, 1-ga > 1
+		return strings.Compare(s.comparable(), releaseQualifier)
+	case stringItem:
+		return strings.Compare(s.comparable(), o.comparable())
+	default:
+		// 1.any < 1.1 and 1.any < 1-1
+		return -1
 	}
-	vp, wp := padForComparison(*v, w)
-	for i := range vp.fields {
-		if vp.fields[i].dot != wp.fields[i].dot {
-			return false
+}
+
+func (s stringItem) String() string {
+	return string(s)
+}
+
+// listItem is a sub list of items, started by a '-' or a transition between digits and letters.
+// While parsing, sub lists are referenced by pointer so they can be appended to after being added to their parent.
+type listItem []item
+
+func (l listItem) isNull() bool {
+	return len(l) == 0
+}
+
+// normalise removes trailing null items: 0, "" and empty lists.
+func (l *listItem) normalise() {
+	for i := len(*l) - 1; i >= 0; i-- {
+		it := (*l)[i]
+		if p, ok := it.(*listItem); ok {
+			it = *p
 		}
-		if vp.fields[i].numeric != wp.fields[i].numeric {
-			return false
+		if it.isNull() {
+			*l = append((*l)[:i], (*l)[i+1:]...)
+		} else if _, ok := it.(listItem); !ok {
+			break
 		}
-		if vp.fields[i].numeric {
-			if vp.fields[i].numericValue != wp.fields[i].numericValue {
-				return false
-			}
-		} else {
-			vstr := strings.ToLower(vp.fields[i].value)
-			wstr := strings.ToLower(wp.fields[i].value)
-			t := map[string]string{
-				"alpha":     "a",
-				"beta":      "b",
-				"milestone": "m",
-				"rc":        "cr",
-				"final":     "",
-				"ga":        "",
-			}
-			if s, ok := t[vstr]; ok {
-				vstr = s
+	}
+}
+
+// resolve replaces the sub list pointers used during parsing with values.
+func (l *listItem) resolve() listItem {
+	r := make(listItem, len(*l))
+	for i, it := range *l {
+		if p, ok := it.(*listItem); ok {
+			it = p.resolve()
+		}
+		r[i] = it
+	}
+	return r
+}
+
+func (l listItem) compare(o item) int {
+	switch o := o.(type) {
+	case nil:
+		// 1-0 = 1- (normalised) = 1
+		// compare each item with null, not just the first one (MNG-6964)
+		for _, it := range l {
+			if r := it.compare(nil); r != 0 {
+				return r
 			}
-			if s, ok := t[wstr]; ok {
-				wstr = s
+		}
+		return 0
+	case intItem:
+		// 1-1 < 1.0.x
+		return -1
+	case stringItem:
+		// 1-1 > 1-sp
+		return 1
+	case listItem:
+		for i := 0; i < len(l) || i < len(o); i++ {
+			var r int
+			switch {
+			case i >= len(l):
+				// this is shorter so invert the comparison
+				r = -1 * o[i].compare(nil)
+			case i >= len(o):
+				r = l[i].compare(nil)
+			default:
+				r = l[i].compare(o[i])
 			}
-			if vstr != wstr {
-				return false
+			if r != 0 {
+				return r
 			}
 		}
+		return 0
 	}
-	return true
+	return 0
 }
 
-// padForComparison stretches the shorter version by padding with enough "null" values with matching prefix to have the
-// same length as the longer one. Padded "null" values depend on the prefix of the other version: 0 for '.', "" for '-'.
-func padForComparison(v, w Version) (Version, Version) {
-	if len(w.fields) == len(v.fields) {
-		return v, w
-	}
-	v, _ = New(v.normalised)
-	w, _ = New(w.normalised)
-	if len(w.fields) > len(v.fields) {
-		for i := len(v.fields); i < len(w.fields); i++ {
-			val := ""
-			var numeric bool
-			if w.fields[i].dot {
-				val = "0"
-				numeric = true
+func (l listItem) String() string {
+	var b strings.Builder
+	for _, it := range l {
+		if b.Len() > 0 {
+			if _, ok := it.(listItem); ok {
+				b.WriteByte('-')
+			} else {
+				b.WriteByte('.')
 			}
-			v.fields = append(v.fields, vfield{dot: w.fields[i].dot, numeric: numeric, value: val})
 		}
-		return v, w
+		b.WriteString(it.String())
 	}
-	for i := len(w.fields); i < len(v.fields); i++ {
-		val := ""
-		var numeric bool
-		if v.fields[i].dot {
-			val = "0"
-			numeric = true
-		}
-		w.fields = append(w.fields, vfield{dot: v.fields[i].dot, numeric: numeric, value: val})
+	return b.String()
+}
+
+// String returns the version string as it was provided
+func (v *Version) String() string {
+	return v.value
+}
+
+// TrimmedString returns the version in a trimmed (canonical) format.
+// See "Trimmed Examples" at https://maven.apache.org/pom.html
+func (v *Version) TrimmedString() string {
+	return v.canonical
+}
+
+// Compare returns -1 if the Version v is less than the Version w, 0 if they are equal and 1 if v is greater than w
+func (v Version) Compare(w Version) int {
+	r := v.items.compare(w.items)
+	switch {
+	case r < 0:
+		return -1
+	case r > 0:
+		return 1
 	}
-	return v, w
+	return 0
+}
+
+// Less indicates if the Version v is less than the Version w
+func (v Version) Less(w Version) bool {
+	return v.Compare(w) < 0
+}
+
+// Equal evaluates if the version provided is equal to this version
+func (v *Version) Equal(w Version) bool {
+	return v.Compare(w) == 0
 }
 
 func (v *Version) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
@@ -384,151 +388,32 @@ func (v Versions) Less(i, j int) bool {
 	return v[i].Less(v[j])
 }
 
-//Version requirements have the following syntax:
-//
-//1.0: "Soft" requirement on 1.0 (just a recommendation, if it matches all other ranges for the dependency)
-//[1.0]: "Hard" requirement on 1.0
-//(,1.0]: x <= 1.0
-//[1.2,1.3]: 1.2 <= x <= 1.3
-//[1.0,2.0): 1.0 <= x < 2.0
-//[1.5,): x >= 1.5
-//(,1.0],[1.2,): x <= 1.0 or x >= 1.2; multiple sets are comma-separated
-//(,1.1),(1.1,): this excludes 1.1 (for example if it is known not to work in combination with this library)
-
-// If [n then n <= x
-// if n] then x <= n
-
-// if (n then n < x
-// if n) then x < n
-
-// Invalid combinations:
-// curved brackets with only one number
-// curved bracket with same number twice
-// first number greater than 2nd
-// numbers not in brackets with comma
-
-type condition struct {
-	openBracketSquare  bool
-	closeBracketSquare bool
-	lower              Version
-	upper              Version
-	undefLower         bool // lower is undefined
-	undefUpper         bool // upper is undefined
-}
-
-func parseRequirement(s string) (conds []condition, err error) {
-	i := strings.IndexAny(s, "[]()")
-	if i == -1 {
-		if strings.Contains(s, ",") {
-			err = errors.New("invalid version requirements")
-			return
-		}
-		// there are no brackets
-		var c condition
-		c.lower, err = New(s)
-		if err != nil {
-			err = fmt.Errorf("could not parse version condition %s: %v", s, err)
-			return
-		}
-		c.upper = c.lower
-		conds = append(conds, c)
-		return
-	}
-	idx := regexp.MustCompile(`[\)\]\(\[]`).FindAllStringIndex(s, -1)
-	if idx[0][0] != 0 || len(s) > idx[len(idx)-1][1] {
-		// there are characters outside of brackets. eg [1.0,1.2),1.3 or 0.9,[1.0,1.2)
-		err = errors.New("invalid version requirements")
-		return
-	}
-	for i := range idx {
-		if i%2 == 0 {
-			continue
-		}
-		f := idx[i-1][1]
-		l := idx[i][0]
-		//if l < len(s)-1 {
-		//	l++
-		//}
-		c := condition{
-			openBracketSquare:  string(s[f-1]) == "[",
-			closeBracketSquare: string(s[l]) == "]",
-		}
-		v := strings.Split(s[f:l], ",")
-		if len(v) == 1 {
-			c.lower, err = New(v[0])
-			if err != nil {
-				err = fmt.Errorf("could not parse lower condition version %s: %v", v[0], err)
-				return
-			}
-			c.upper = c.lower
-		} else {
-			if v[0] != "" {
-				c.lower, err = New(v[0])
-				if err != nil {
-					err = fmt.Errorf("could not parse lower condition version %s: %v", v[0], err)
-					return
-				}
-			} else {
-				c.undefLower = true
-			}
-			if v[1] != "" {
-				c.upper, err = New(v[1])
-				if err != nil {
-					err = fmt.Errorf("could not parse upper condition version %s: %v", v[1], err)
-					return
-				}
-			} else {
-				c.undefUpper = true
-			}
-		}
-		if !c.undefUpper && !c.undefLower && c.upper.Less(c.lower) {
-			err = errors.New("invalid version requirements")
-			return
-		}
-		if (!c.closeBracketSquare || !c.openBracketSquare) && c.upper.Equal(c.lower) {
-			// curved brackets with only one number
-			// curved bracket with same number twice
-			err = errors.New("invalid version requirements")
-			return
-		}
-		conds = append(conds, c)
-	}
-	return
-}
-
-// Satisfies indicates if this version meets the requirement string provided
+// Satisfies indicates if this version meets the requirement string provided.
+// False is returned if the requirement is not a valid range. Use ParseRange and Range.Contains to handle the error.
 func (v Version) Satisfies(r string) bool {
-	conds, err := parseRequirement(r)
+	vr, err := ParseRange(r)
 	if err != nil {
 		return false
 	}
-	for _, c := range conds {
-		var ls, us bool
-		if c.undefLower {
-			ls = true
-		} else {
-			if c.lower.Less(v) {
-				ls = true
-			}
-			if c.openBracketSquare && v.Equal(c.lower) {
-				ls = true
-			}
-		}
-		if c.undefUpper {
-			us = true
-		} else {
-			if v.Less(c.upper) {
-				us = true
-			}
-			if c.closeBracketSquare && v.Equal(c.upper) {
-				us = true
-			}
+	return vr.Contains(v)
+}
+
+// Highest returns the highest version in the slice that is within the range.
+// As with maven a soft requirement does not restrict the versions, so the highest version overall is returned.
+// A NoMatch error is returned if none of the versions are within the range.
+func (v Versions) Highest(r Range) (Version, error) {
+	var h *Version
+	for i := range v {
+		if r.Contains(v[i]) && (h == nil || h.Less(v[i])) {
+			h = &v[i]
 		}
-		if ls && us {
-			return true
+	}
+	if h == nil {
+		return Version{}, NoMatch{
+			ErrorString: fmt.Sprintf("no version within %s", r.String()),
 		}
 	}
-	return false
+	return *h, nil
 }
 
 func (v *Versions) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
@@ -1,6 +1,7 @@
 package version
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -31,39 +32,6 @@ func TestNew(t *testing.T) {
 	}
 }
 
-func TestPadForComparison(t *testing.T) {
-	tests := []struct {
-		vstr string
-		wstr string
-	}{
-		{"1.1.1", "1"},
-		{"1.0", "1.1.2"},
-		{"1.0", "1.1-2"},
-		{"1.1.1", "1.1.2"},
-		{"1-1.1", "1.1.2"},
-	}
-	for _, test := range tests {
-		v, _ := New(test.vstr)
-		w, _ := New(test.wstr)
-		vp, wp := padForComparison(v, w)
-		smaller := &vp
-		bigger := &wp
-		if len(w.fields) < len(v.fields) {
-			smaller = &wp
-			bigger = &vp
-		}
-		assert.Equal(t, len(vp.fields), len(wp.fields))
-		for c := len(smaller.fields); c < len(bigger.fields); c++ {
-			assert.Equal(t, bigger.fields[c].dot, smaller.fields[c].dot)
-			if smaller.fields[c].dot {
-				assert.Equal(t, "0", smaller.fields[c].value)
-			} else {
-				assert.Equal(t, "", smaller.fields[c].value)
-			}
-		}
-	}
-}
-
 func TestVersions_Less(t *testing.T) {
 	tests := []struct {
 		lesser  string
@@ -193,37 +161,6 @@ func TestVersions_Less(t *testing.T) {
 	}
 }
 
-func TestParseRequirements(t *testing.T) {
-	tests := []struct {
-		req string
-	}{
-		//1.0: "Soft" requirement on 1.0 (just a recommendation, if it matches all other ranges for the dependency)
-		//[1.0]: "Hard" requirement on 1.0
-		//(,1.0]: x <= 1.0
-		//[1.2,1.3]: 1.2 <= x <= 1.3
-		//[1.0,2.0): 1.0 <= x < 2.0
-		//[1.5,): x >= 1.5
-		//(,1.0],[1.2,): x <= 1.0 or x >= 1.2; multiple sets are comma-separated
-		//(,1.1),(1.1,)
-		{"1.0"},
-		{"[1.0]"},
-		{"(,1.0]"},
-		{"[1.2,1.3]"},
-		{"[1.0,2.0)"},
-		{"[1.5,)"},
-		{"(,1.0],[1.2,)"},
-		{"(,1.1),(1.1,)"},
-	}
-	for _, test := range tests {
-		c, err := parseRequirement(test.req)
-		if err != nil {
-			t.Errorf("could not parse requirement %s: %v", test.req, err)
-		}
-		assert.True(t, len(c) > 0)
-		//t.Logf("%s\n%+v\n\n", test.req, c)
-	}
-}
-
 func TestVersion_Equal(t *testing.T) {
 	tests := []struct {
 		v string
@@ -287,6 +224,45 @@ func TestVersion_Equal(t *testing.T) {
 	}
 }
 
+func TestVersions_Highest(t *testing.T) {
+	vs, err := NewVersions([]string{"1.0", "1.1", "2.0-alpha-1", "2.0", "2.1", "3.0-SNAPSHOT"})
+	if err != nil {
+		t.Fatalf("could not create versions: %v", err)
+	}
+	tests := []struct {
+		req      string
+		expected string
+	}{
+		{"[1.0,1.5)", "1.1"},
+		// pre-releases of the upper bound are within the range
+		{"[1.0,2.0)", "2.0-alpha-1"},
+		{"[1.0,2.0]", "2.0"},
+		{"(,2.0)", "2.0-alpha-1"},
+		{"[1.0]", "1.0"},
+		{"[2.0,)", "3.0-SNAPSHOT"},
+		{"(,1.0],[2.0,2.1)", "2.0"},
+		// a soft requirement does not restrict the versions
+		{"1.0", "3.0-SNAPSHOT"},
+	}
+	for _, test := range tests {
+		r, err := ParseRange(test.req)
+		if err != nil {
+			t.Fatalf("could not parse range %s: %v", test.req, err)
+		}
+		v, err := vs.Highest(r)
+		if err != nil {
+			t.Errorf("error getting highest version within %s: %v", test.req, err)
+			continue
+		}
+		assert.Equal(t, test.expected, v.String(), "highest version within %s", test.req)
+	}
+	r, _ := ParseRange("[4.0,)")
+	_, err = vs.Highest(r)
+	if _, ok := err.(NoMatch); !ok {
+		t.Errorf("expected NoMatch error, got: %v", err)
+	}
+}
+
 func TestVersion_Satisfies(t *testing.T) {
 	tests := []struct {
 		req       string
@@ -343,62 +319,239 @@ func TestVersion_Satisfies(t *testing.T) {
 	}
 }
 
-func TestParseRequirement_Invalid(t *testing.T) {
-	tests := []string{
-		"(1.0)",
-		"[1.0)",
-		"(1.0]",
-		"(1.0,1.0]",
-		"[1.0,1.0)",
-		"(1.0,1.0)",
-		"[1.1,1.0]",
-		"[1.0,1.2),1.3",
-		// overlap
-		//"[1.0,1.2),(1.1,1.3]" ,
-		//// overlap
-		//"[1.1,1.3),(1.0,1.2]" ,
-		//// ordering
-		//"(1.1,1.2],[1.0,1.1)" ,
+func TestTrimmedString(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"1-1.foo-bar1baz-.1", "1-1.foo-bar-1-baz-0.1"},
+		{"1.0.0-0.0.0", "1"},
+		{"1.0-alpha", "1-alpha"},
+		{"1.0.RC1", "1.0.rc-1"},
+		{"1-a1", "1-alpha-1"},
+		{"1.0-ga-sp", "1-sp"},
+		{"1.0-0-1", "1-1"},
 	}
 	for _, test := range tests {
-		_, err := parseRequirement(test)
-		assert.NotNil(t, err, "did not error on invalid requirement: %s", test)
+		v, err := New(test.in)
+		if err != nil {
+			t.Errorf("could not create version from %s: %v", test.in, err)
+		}
+		assert.Equal(t, test.out, v.TrimmedString(), "canonical form of %s incorrect", test.in)
+	}
+}
+
+func TestNew_Empty(t *testing.T) {
+	_, err := New("")
+	assert.NotNil(t, err, "empty version string should error")
+}
+
+// The tests below are the matrix from maven's ComparableVersionTest
+
+// newComparable creates a version and checks that parsing the canonical form is stable.
+func newComparable(t *testing.T, s string) Version {
+	v, err := New(s)
+	if err != nil {
+		t.Fatalf("could not create version from %s: %v", s, err)
+	}
+	c := v.TrimmedString()
+	if c == "" {
+		return v
+	}
+	w, err := New(c)
+	if err != nil {
+		t.Fatalf("could not create version from canonical %s: %v", c, err)
+	}
+	assert.Equal(t, c, w.TrimmedString(), "canonical(%s) = %s -> canonical: %s", s, c, w.TrimmedString())
+	return v
+}
+
+func checkVersionsOrder(t *testing.T, vs ...string) {
+	c := make([]Version, len(vs))
+	for i, s := range vs {
+		c[i] = newComparable(t, s)
+	}
+	for i := 1; i < len(vs); i++ {
+		low := c[i-1]
+		for j := i; j < len(vs); j++ {
+			high := c[j]
+			assert.True(t, low.Compare(high) < 0, "expected %s < %s", vs[i-1], vs[j])
+			assert.True(t, high.Compare(low) > 0, "expected %s > %s", vs[j], vs[i-1])
+		}
+	}
+}
+
+func checkVersionsEqual(t *testing.T, vs ...string) {
+	c := make([]Version, len(vs))
+	for i, s := range vs {
+		c[i] = newComparable(t, s)
+	}
+	for i := range c {
+		for j := i + 1; j < len(c); j++ {
+			assert.True(t, c[i].Equal(c[j]), "expected %s == %s", vs[i], vs[j])
+			assert.True(t, c[j].Equal(c[i]), "expected %s == %s", vs[j], vs[i])
+			assert.Equal(t, c[i].TrimmedString(), c[j].TrimmedString(), "expected same canonical form for %s and %s", vs[i], vs[j])
+		}
 	}
 }
 
-func TestNormaliseVersion(t *testing.T) {
+func TestComparableVersion_Qualifier(t *testing.T) {
+	checkVersionsOrder(t, "1-alpha2snapshot", "1-alpha2", "1-alpha-123", "1-beta-2", "1-beta123", "1-m2", "1-m11",
+		"1-rc", "1-cr2", "1-rc123", "1-SNAPSHOT", "1", "1-sp", "1-sp2", "1-sp123", "1-abc", "1-def", "1-pom-1",
+		"1-1-snapshot", "1-1", "1-2", "1-123")
+}
+
+func TestComparableVersion_Number(t *testing.T) {
+	checkVersionsOrder(t, "2.0", "2-1", "2.0.a", "2.0.0.a", "2.0.2", "2.0.123", "2.1.0", "2.1-a", "2.1b", "2.1-c",
+		"2.1-1", "2.1.0.1", "2.2", "2.123", "11.a2", "11.a11", "11.b2", "11.b11", "11.m2", "11.m11", "11", "11.a",
+		"11b", "11c", "11m")
+}
+
+func TestComparableVersion_Equal(t *testing.T) {
+	newComparable(t, "1.0-alpha")
 	tests := []struct {
-		in  string
-		out string
+		v string
+		w string
 	}{
-		{"1-1.foo-bar1baz-.1", "1-1.foo-bar-1-baz-0.1"},
+		{"1", "1"},
+		{"1", "1.0"},
+		{"1", "1.0.0"},
+		{"1.0", "1.0.0"},
+		{"1", "1-0"},
+		{"1", "1.0-0"},
+		{"1.0", "1.0-0"},
+		// no separator between number and character
+		{"1a", "1-a"},
+		{"1a", "1.0-a"},
+		{"1a", "1.0.0-a"},
+		{"1.0a", "1-a"},
+		{"1.0.0a", "1-a"},
+		{"1x", "1-x"},
+		{"1x", "1.0-x"},
+		{"1x", "1.0.0-x"},
+		{"1.0x", "1-x"},
+		{"1.0.0x", "1-x"},
+
+		// aliases
+		{"1ga", "1"},
+		{"1release", "1"},
+		{"1final", "1"},
+		{"1cr", "1rc"},
+
+		// special "aliases" a, b and m for alpha, beta and milestone
+		{"1a1", "1-alpha-1"},
+		{"1b2", "1-beta-2"},
+		{"1m3", "1-milestone-3"},
+
+		// case insensitive
+		{"1X", "1x"},
+		{"1A", "1a"},
+		{"1B", "1b"},
+		{"1M", "1m"},
+		{"1Ga", "1"},
+		{"1GA", "1"},
+		{"1RELEASE", "1"},
+		{"1release", "1"},
+		{"1RELeaSE", "1"},
+		{"1Final", "1"},
+		{"1FinaL", "1"},
+		{"1FINAL", "1"},
+		{"1Cr", "1Rc"},
+		{"1cR", "1rC"},
+		{"1m3", "1Milestone3"},
+		{"1m3", "1MileStone3"},
+		{"1m3", "1MILESTONE3"},
 	}
 	for _, test := range tests {
-		norm := normaliseVersion(test.in)
-		assert.Equal(t, test.out, norm, "normalisation of %s incorrect", test.in)
+		checkVersionsEqual(t, test.v, test.w)
 	}
 }
 
-func TestHyphenateAlphaNumeric(t *testing.T) {
+func TestComparableVersion_Comparing(t *testing.T) {
 	tests := []struct {
-		in  string
-		out string
+		lesser  string
+		greater string
 	}{
-		{"foo1bar", "foo-1-bar"},
-		{"foo-1bar", "foo-1-bar"},
-		{"foo-1-bar", "foo-1-bar"},
-		{"foo1", "foo-1"},
-		{"1bar", "1-bar"},
-		{"foo-1-bar2foo", "foo-1-bar-2-foo"},
-		{"foo123bar", "foo-123-bar"},
-		{"foo-123bar", "foo-123-bar"},
-		{"foo-123-bar", "foo-123-bar"},
-		{"foo123", "foo-123"},
-		{"123bar", "123-bar"},
-		{"foo-bar-1baz-0", "foo-bar-1-baz-0"},
+		{"1", "2"},
+		{"1.5", "2"},
+		{"1", "2.5"},
+		{"1.0", "1.1"},
+		{"1.1", "1.2"},
+		{"1.0.0", "1.1"},
+		{"1.0.1", "1.1"},
+		{"1.1", "1.2.0"},
+
+		{"1.0-alpha-1", "1.0"},
+		{"1.0-alpha-1", "1.0-alpha-2"},
+		{"1.0-alpha-1", "1.0-beta-1"},
+
+		{"1.0-beta-1", "1.0-SNAPSHOT"},
+		{"1.0-SNAPSHOT", "1.0"},
+		{"1.0-alpha-1-SNAPSHOT", "1.0-alpha-1"},
+
+		{"1.0", "1.0-1"},
+		{"1.0-1", "1.0-2"},
+		{"1.0.0", "1.0-1"},
+
+		{"2.0-1", "2.0.1"},
+		{"2.0.1-klm", "2.0.1-lmn"},
+		{"2.0.1", "2.0.1-xyz"},
+
+		{"2.0.1", "2.0.1-123"},
+		{"2.0.1-xyz", "2.0.1-123"},
 	}
 	for _, test := range tests {
-		norm := hyphenateAlphaNumeric(test.in)
-		assert.Equal(t, test.out, norm, "hypenating of %s incorrect", test.in)
+		checkVersionsOrder(t, test.lesser, test.greater)
 	}
 }
+
+// MNG-5568: ordering must be transitive even with unusual version strings.
+func TestComparableVersion_MNG5568(t *testing.T) {
+	a := "6.1.0"
+	b := "6.1.0rc3"
+	c := "6.1H.5-beta" // this is the unusual version string, with 'H' in the middle
+
+	checkVersionsOrder(t, b, a) // classical
+	checkVersionsOrder(t, b, c) // now b < c, but before MNG-5568, we had b > c
+	checkVersionsOrder(t, a, c)
+}
+
+// MNG-6572: numbers of any size compare numerically.
+func TestComparableVersion_MNG6572(t *testing.T) {
+	a := "20190126.230843"                // resembles a SNAPSHOT
+	b := "1234567890.12345"               // 10 digit number
+	c := "123456789012345.1H.5-beta"      // 15 digit number
+	d := "12345678901234567890.1H.5-beta" // 20 digit number
+
+	checkVersionsOrder(t, a, b)
+	checkVersionsOrder(t, b, c)
+	checkVersionsOrder(t, a, c)
+	checkVersionsOrder(t, c, d)
+	checkVersionsOrder(t, b, d)
+	checkVersionsOrder(t, a, d)
+}
+
+func TestComparableVersion_LeadingZeroes(t *testing.T) {
+	var ones, zeros []string
+	for i := 19; i > 0; i-- {
+		ones = append(ones, strings.Repeat("0", i-1)+"1")
+		zeros = append(zeros, strings.Repeat("0", i))
+	}
+	checkVersionsEqual(t, ones...)
+	checkVersionsEqual(t, zeros...)
+}
+
+// MNG-6964: qualifiers starting with "-0." must not compare equal to the release.
+func TestComparableVersion_MNG6964(t *testing.T) {
+	a := "1-0.alpha"
+	b := "1-0.beta"
+	c := "1"
+
+	checkVersionsOrder(t, a, c) // Now a < c, but before MNG-6964 they were equal
+	checkVersionsOrder(t, b, c) // Now b < c, but before MNG-6964 they were equal
+	checkVersionsOrder(t, a, b) // Should still be true
+}
+
+func TestComparableVersion_LocaleIndependent(t *testing.T) {
+	checkVersionsEqual(t, "1-abcdefghijklmnopqrstuvwxyz", "1-ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}